@@ -33,7 +33,20 @@ type AesCrypto struct {
 
 const AesIvSize = 16
 
+var errAadRequiresGcm = fmt.Errorf("Associated data requires GCM mode")
+
+const (
+	gcmStandardNonceSize = 12
+	gcmStandardTagSize   = 16
+)
+
 func (crypto AesCrypto) Encrypt(plainTextBytes []byte, key []byte) (string, error) {
+	return crypto.EncryptWithAad(plainTextBytes, key, nil)
+}
+
+// EncryptWithAad encrypts like Encrypt, authenticating aad, e.g. a tenant ID, as additional data
+// so decryption fails unless the same aad is passed. Only GCM mode supports aad.
+func (crypto AesCrypto) EncryptWithAad(plainTextBytes []byte, key []byte, aad []byte) (string, error) {
 	// create a new aes cipher using key
 	aes, err := aes.NewCipher(key)
 	if err != nil {
@@ -41,13 +54,20 @@ func (crypto AesCrypto) Encrypt(plainTextBytes []byte, key []byte) (string, erro
 	}
 
 	if crypto.CipherMode == GCM {
-		return crypto.EncryptGcm(aes, plainTextBytes)
+		return crypto.EncryptGcmWithAad(aes, plainTextBytes, aad)
 	} else {
+		if len(aad) > 0 {
+			return "", errAadRequiresGcm
+		}
 		return crypto.EncryptCbc(aes, plainTextBytes)
 	}
 }
 
 func (crypto AesCrypto) EncryptGcm(aes cipher.Block, plainTextBytes []byte) (string, error) {
+	return crypto.EncryptGcmWithAad(aes, plainTextBytes, nil)
+}
+
+func (crypto AesCrypto) EncryptGcmWithAad(aes cipher.Block, plainTextBytes []byte, aad []byte) (string, error) {
 	gcm, err := cipher.NewGCM(aes)
 	if err != nil {
 		return "", err
@@ -58,7 +78,7 @@ func (crypto AesCrypto) EncryptGcm(aes cipher.Block, plainTextBytes []byte) (str
 		return "", err
 	}
 
-	cipherText := gcm.Seal(nil, nonce, plainTextBytes, nil)
+	cipherText := gcm.Seal(nil, nonce, plainTextBytes, aad)
 
 	return crypto.PackCipherData(cipherText, nonce, gcm.Overhead()), nil
 }
@@ -82,13 +102,37 @@ func (crypto AesCrypto) EncryptCbc(aes cipher.Block, plainTextBytes []byte) (str
 	return crypto.PackCipherData(cipherText, iv, 0), nil
 }
 
+// Decrypt decrypts cipherText, either an envelope (see EncryptEnvelope), of which the mode is read
+// from the envelope, or a legacy blob as returned by Encrypt, using CipherMode.
 func (crypto AesCrypto) Decrypt(cipherText string, key []byte, provider string) (string, error) {
+	return crypto.DecryptWithAad(cipherText, key, provider, nil)
+}
+
+// DecryptWithAad decrypts like Decrypt, cipherText being encrypted with aad as additional data
+func (crypto AesCrypto) DecryptWithAad(cipherText string, key []byte, provider string, aad []byte) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(cipherText)
 	if err != nil {
 		return "", err
 	}
 
-	encryptedBytes, iv, tagSize := crypto.UnpackCipherData(data)
+	envelope, err := parseEnvelope(data)
+	if err != nil {
+		return crypto.decryptLegacy(data, key, provider, aad)
+	}
+
+	plainTextBytes, err := envelope.decrypt(key, aad)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plainTextBytes), nil
+}
+
+func (crypto AesCrypto) decryptLegacy(data []byte, key []byte, provider string, aad []byte) (string, error) {
+	encryptedBytes, iv, tagSize, err := crypto.unpackCipherData(data)
+	if err != nil {
+		return "", err
+	}
 
 	aes, err := aes.NewCipher(key)
 	if err != nil {
@@ -96,26 +140,38 @@ func (crypto AesCrypto) Decrypt(cipherText string, key []byte, provider string)
 	}
 
 	if crypto.CipherMode == GCM {
-		return DecryptGcm(aes, encryptedBytes, iv, tagSize, provider)
+		return DecryptGcmWithAad(aes, encryptedBytes, iv, tagSize, provider, aad)
 	} else {
+		if len(aad) > 0 {
+			return "", errAadRequiresGcm
+		}
 		return DecryptCbc(aes, encryptedBytes, iv)
 	}
 }
 
 func DecryptGcm(aes cipher.Block, encrypted []byte, nonce []byte, tagSize int, provider string) (string, error) {
+	return DecryptGcmWithAad(aes, encrypted, nonce, tagSize, provider, nil)
+}
+
+func DecryptGcmWithAad(aes cipher.Block, encrypted []byte, nonce []byte, tagSize int, provider string, aad []byte) (string, error) {
 	var aesgcm cipher.AEAD
 	var err error
 	if strings.EqualFold("go", provider) {
 		aesgcm, err = cipher.NewGCM(aes)
 	} else {
-		aesgcm, err = cipher.NewGCMWithNonceSize(aes, tagSize) // only used for compatibility, NewGCM recomended
+		// only used for compatibility, NewGCM recomended
+		aesgcm, err = newGcm(aes, len(nonce), tagSize)
 	}
 
 	if err != nil {
 		return "", err
 	}
 
-	decryptedBytes, err := aesgcm.Open(nil, nonce, encrypted, nil)
+	if len(nonce) != aesgcm.NonceSize() {
+		return "", fmt.Errorf("Invalid nonce size %d", len(nonce))
+	}
+
+	decryptedBytes, err := aesgcm.Open(nil, nonce, encrypted, aad)
 	if err != nil {
 		return "", err
 	}
@@ -123,7 +179,30 @@ func DecryptGcm(aes cipher.Block, encrypted []byte, nonce []byte, tagSize int, p
 	return string(decryptedBytes[:len(decryptedBytes)]), nil
 }
 
+// newGcm returns GCM with the given nonce and tag size, Go supporting non-standard sizes for one of both only
+func newGcm(aes cipher.Block, nonceSize int, tagSize int) (cipher.AEAD, error) {
+	if nonceSize != gcmStandardNonceSize {
+		if tagSize != 0 && tagSize != gcmStandardTagSize {
+			return nil, fmt.Errorf("Unsupported combination of nonce size %d and tag size %d", nonceSize, tagSize)
+		}
+		return cipher.NewGCMWithNonceSize(aes, nonceSize)
+	}
+
+	if tagSize != 0 && tagSize != gcmStandardTagSize {
+		return cipher.NewGCMWithTagSize(aes, tagSize)
+	}
+
+	return cipher.NewGCM(aes)
+}
+
 func DecryptCbc(aes cipher.Block, encrypted []byte, iv []byte) (string, error) {
+	if len(iv) != aes.BlockSize() {
+		return "", fmt.Errorf("Invalid iv size %d", len(iv))
+	}
+	if len(encrypted)%aes.BlockSize() != 0 {
+		return "", fmt.Errorf("Invalid data length %d", len(encrypted))
+	}
+
 	decryptor := cipher.NewCBCDecrypter(aes, iv)
 
 	decryptedBytes := make([]byte, len(encrypted))
@@ -160,18 +239,31 @@ func (crypto AesCrypto) PackCipherData(cipherText []byte, iv []byte, tagSize int
 	return base64.StdEncoding.EncodeToString(data)
 }
 
+// UnpackCipherData splits data as packed by PackCipherData, returning nil slices if data is invalid
 func (crypto AesCrypto) UnpackCipherData(data []byte) ([]byte, []byte, int) {
+	encryptedBytes, iv, tagSize, _ := crypto.unpackCipherData(data)
+
+	return encryptedBytes, iv, tagSize
+}
+
+func (crypto AesCrypto) unpackCipherData(data []byte) ([]byte, []byte, int, error) {
 	ivSize := AesIvSize
 	index := 0
 	tagSize := 0
 	if crypto.CipherMode == GCM {
+		if len(data) < 2 {
+			return nil, nil, 0, fmt.Errorf("Invalid cipher data length %d", len(data))
+		}
 		ivSize = int(data[0])
-		tagSize = int(data[index])
+		tagSize = int(data[1])
 		index += 2
 	}
+	if ivSize == 0 || len(data) < index+ivSize {
+		return nil, nil, 0, fmt.Errorf("Invalid cipher data length %d", len(data))
+	}
 	iv, encryptedBytes := data[index:index+ivSize], data[index+ivSize:]
 
-	return encryptedBytes, iv, tagSize
+	return encryptedBytes, iv, tagSize, nil
 }
 
 // ref: https://golang-examples.tumblr.com/post/98350728789/pkcs7-padding
@@ -212,18 +304,23 @@ func pkcs7Unpad(data []byte, blocklen int) ([]byte, error) {
 	return data[:len(data)-padlen], nil
 }
 
+// LegacyKey derives the key used by Encrypt and Decrypt from passphrase: SHA-512 truncated to 24 bytes
+func LegacyKey(passphrase string) []byte {
+	sha512Hash := sha512.New()
+	sha512Hash.Write([]byte(passphrase))
+
+	h := sha512Hash.Sum(nil)
+
+	return h[:24]
+}
+
 func Encrypt(b []byte, cipherKey string) (string, error) {
 	a := AesCrypto{
 		Padding:    NoPadding,
 		CipherMode: CBC,
 	}
 
-	sha512Hash := sha512.New()
-	sha512Hash.Write([]byte(cipherKey))
-
-	h := sha512Hash.Sum(nil)
-
-	encrypted, err := a.Encrypt(b, h[:24])
+	encrypted, err := a.Encrypt(b, LegacyKey(cipherKey))
 	if err != nil {
 		return "", err
 	}
@@ -231,16 +328,22 @@ func Encrypt(b []byte, cipherKey string) (string, error) {
 	return encrypted, nil
 }
 
+// Decrypt decrypts b as returned by EncryptWithKdf or Encrypt
 func Decrypt(b []byte, cipherKey string) (string, error) {
 	a := AesCrypto{
 		Padding:    NoPadding,
 		CipherMode: CBC,
 	}
 
-	sha512Hash := sha512.New()
-	sha512Hash.Write([]byte(cipherKey))
+	return a.DecryptWithPassphrase(string(b), cipherKey)
+}
 
-	h := sha512Hash.Sum(nil)
+// DecryptWithAad decrypts b as returned by EncryptWithKdfWithAad, aad being the additional data passed
+func DecryptWithAad(b []byte, cipherKey string, aad []byte) (string, error) {
+	a := AesCrypto{
+		Padding:    NoPadding,
+		CipherMode: CBC,
+	}
 
-	return a.Decrypt(string(b), h[:24], "")
+	return a.DecryptWithPassphraseWithAad(string(b), cipherKey, aad)
 }
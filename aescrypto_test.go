@@ -0,0 +1,78 @@
+package utilities
+
+import (
+	"crypto/aes"
+	"testing"
+)
+
+func TestLegacyCbc(t *testing.T) {
+	// Encrypt still writes legacy blobs
+	cipherText, err := Encrypt([]byte("secret"), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseEnvelope(cipherText); err == nil {
+		t.Fatal("legacy blob parsed as envelope")
+	}
+
+	decrypted, err := Decrypt([]byte(cipherText), "passphrase")
+	if err != nil || decrypted != "secret" {
+		t.Fatalf("decrypted '%s', error %v", decrypted, err)
+	}
+
+	// a blob packed by EncryptCbc
+	key := testKey(t, 24)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crypto := AesCrypto{CipherMode: CBC}
+	cipherText, err = crypto.EncryptCbc(block, []byte("exactly 16 bytes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err = crypto.Decrypt(cipherText, key, "")
+	if err != nil || decrypted != "exactly 16 bytes" {
+		t.Fatalf("decrypted '%s', error %v", decrypted, err)
+	}
+}
+
+func TestLegacyGcm(t *testing.T) {
+	crypto := AesCrypto{CipherMode: GCM}
+	key := testKey(t, 32)
+
+	cipherText, err := crypto.Encrypt([]byte("secret"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ParseEnvelope(cipherText); err == nil {
+		t.Fatal("legacy blob parsed as envelope")
+	}
+
+	decrypted, err := crypto.Decrypt(cipherText, key, "")
+	if err != nil || decrypted != "secret" {
+		t.Fatalf("decrypted '%s', error %v", decrypted, err)
+	}
+
+	if _, err = crypto.Decrypt(cipherText, testKey(t, 32), ""); err == nil {
+		t.Fatal("legacy blob decrypted with wrong key")
+	}
+}
+
+func TestLegacyGcmAad(t *testing.T) {
+	crypto := AesCrypto{CipherMode: GCM}
+	key := testKey(t, 32)
+
+	cipherText, err := crypto.EncryptWithAad([]byte("secret"), key, []byte("tenant-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = crypto.DecryptWithAad(cipherText, key, "", []byte("tenant-2")); err == nil {
+		t.Fatal("legacy blob decrypted with other aad")
+	}
+	decrypted, err := crypto.DecryptWithAad(cipherText, key, "", []byte("tenant-1"))
+	if err != nil || decrypted != "secret" {
+		t.Fatalf("decrypted '%s', error %v", decrypted, err)
+	}
+}
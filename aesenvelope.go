@@ -0,0 +1,357 @@
+package utilities
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// CipherAlgorithm identifies the algorithm, including key size, of an Envelope
+type CipherAlgorithm byte
+
+const (
+	AES128 CipherAlgorithm = iota + 1
+	AES192
+	AES256
+)
+
+const (
+	envelopeVersion1 byte = 1
+	envelopeVersion2 byte = 2 // adds key derivation
+)
+
+var envelopeMagic = []byte("AEV")
+
+// Envelope is a self-describing ciphertext, stored as
+//
+//	magic "AEV" | version | algorithm | mode | key ID length | key ID | nonce length | nonce | tag size | ciphertext
+//
+// Version 2 envelopes, holding a key derived from a passphrase, add after the tag size
+//
+//	kdf | 3 uint32 kdf parameters (big endian) | salt length | salt
+//
+// In GCM mode the header, all but the ciphertext, is authenticated as additional data.
+// In CBC mode the ciphertext ends with an HMAC-SHA256 tag of the header and encrypted data
+// (encrypt-then-MAC), the encryption and MAC keys being derived from the key.
+type Envelope struct {
+	Version    byte
+	Algorithm  CipherAlgorithm
+	CipherMode CipherMode
+	KeyId      string
+	Nonce      []byte
+	TagSize    int
+	Kdf        Kdf
+	KdfParams  [3]uint32
+	Salt       []byte
+	CipherText []byte
+}
+
+func cipherAlgorithm(key []byte) (CipherAlgorithm, error) {
+	switch len(key) {
+	case 16:
+		return AES128, nil
+	case 24:
+		return AES192, nil
+	case 32:
+		return AES256, nil
+	}
+
+	return 0, fmt.Errorf("Invalid key size %d", len(key))
+}
+
+// EncryptEnvelope encrypts plainTextBytes with key using CipherMode and returns the base64 encoded
+// envelope, storing keyId (at most 255 bytes) to tell which key to decrypt with, see ParseEnvelope.
+func (crypto AesCrypto) EncryptEnvelope(plainTextBytes []byte, key []byte, keyId string) (string, error) {
+	return crypto.EncryptEnvelopeWithAad(plainTextBytes, key, keyId, nil)
+}
+
+// EncryptEnvelopeWithAad encrypts like EncryptEnvelope, authenticating aad, e.g. a tenant ID, as additional
+// data besides the header, so decryption fails unless the same aad is passed. Only GCM mode supports aad.
+func (crypto AesCrypto) EncryptEnvelopeWithAad(plainTextBytes []byte, key []byte, keyId string, aad []byte) (string, error) {
+	envelope := Envelope{
+		Version: envelopeVersion1,
+		KeyId:   keyId,
+	}
+
+	return crypto.seal(&envelope, plainTextBytes, key, aad)
+}
+
+// seal encrypts plainTextBytes with key into envelope, of which the version, key ID and key derivation are set
+func (crypto AesCrypto) seal(envelope *Envelope, plainTextBytes []byte, key []byte, aad []byte) (string, error) {
+	algorithm, err := cipherAlgorithm(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(envelope.KeyId) > 255 {
+		return "", fmt.Errorf("Key ID exceeds 255 bytes")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	if crypto.CipherMode != GCM && len(aad) > 0 {
+		return "", errAadRequiresGcm
+	}
+
+	envelope.Algorithm = algorithm
+	envelope.CipherMode = crypto.CipherMode
+
+	if crypto.CipherMode == GCM {
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return "", err
+		}
+
+		envelope.Nonce = make([]byte, gcm.NonceSize())
+		if _, err = io.ReadFull(rand.Reader, envelope.Nonce); err != nil {
+			return "", err
+		}
+		envelope.TagSize = gcm.Overhead()
+
+		envelope.CipherText = gcm.Seal(nil, envelope.Nonce, plainTextBytes, envelope.additionalData(aad))
+	} else {
+		encryptionKey, macKey := cbcEnvelopeKeys(key)
+
+		block, err = aes.NewCipher(encryptionKey)
+		if err != nil {
+			return "", err
+		}
+
+		envelope.Nonce = make([]byte, AesIvSize)
+		if _, err = io.ReadFull(rand.Reader, envelope.Nonce); err != nil {
+			return "", err
+		}
+		envelope.TagSize = sha256.Size
+
+		plainTextBytes, err = pkcs7Pad(plainTextBytes, block.BlockSize())
+		if err != nil {
+			return "", err
+		}
+
+		encrypted := make([]byte, len(plainTextBytes))
+		cipher.NewCBCEncrypter(block, envelope.Nonce).CryptBlocks(encrypted, plainTextBytes)
+
+		envelope.CipherText = append(encrypted, envelope.mac(macKey, encrypted)...)
+	}
+
+	return envelope.String(), nil
+}
+
+// cbcEnvelopeKeys derives the encryption key and MAC key of a CBC envelope from key
+func cbcEnvelopeKeys(key []byte) ([]byte, []byte) {
+	derive := func(label string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(label))
+		return h.Sum(nil)
+	}
+
+	return derive("AEV CBC encryption")[:len(key)], derive("AEV CBC authentication")
+}
+
+// mac returns the HMAC-SHA256 tag of the header and encrypted data of a CBC envelope
+func (envelope *Envelope) mac(macKey []byte, encrypted []byte) []byte {
+	h := hmac.New(sha256.New, macKey)
+	h.Write(envelope.header())
+	h.Write(encrypted)
+
+	return h.Sum(nil)
+}
+
+// header returns the serialized envelope without ciphertext
+func (envelope *Envelope) header() []byte {
+	header := append([]byte{}, envelopeMagic...)
+	header = append(header, envelope.Version, byte(envelope.Algorithm), byte(envelope.CipherMode))
+	header = append(header, byte(len(envelope.KeyId)))
+	header = append(header, envelope.KeyId...)
+	header = append(header, byte(len(envelope.Nonce)))
+	header = append(header, envelope.Nonce...)
+	header = append(header, byte(envelope.TagSize))
+
+	if envelope.Version >= envelopeVersion2 {
+		header = append(header, byte(envelope.Kdf))
+		for _, param := range envelope.KdfParams {
+			header = binary.BigEndian.AppendUint32(header, param)
+		}
+		header = append(header, byte(len(envelope.Salt)))
+		header = append(header, envelope.Salt...)
+	}
+
+	return header
+}
+
+// additionalData returns the data authenticated in GCM mode: the header followed by aad,
+// the header being self-delimiting
+func (envelope *Envelope) additionalData(aad []byte) []byte {
+	return append(envelope.header(), aad...)
+}
+
+// String returns the base64 encoded envelope
+func (envelope *Envelope) String() string {
+	return base64.StdEncoding.EncodeToString(append(envelope.header(), envelope.CipherText...))
+}
+
+// ParseEnvelope parses cipherText, a base64 encoded envelope as returned by EncryptEnvelope
+func ParseEnvelope(cipherText string) (*Envelope, error) {
+	data, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseEnvelope(data)
+}
+
+func isEnvelope(data []byte) bool {
+	return bytes.HasPrefix(data, envelopeMagic)
+}
+
+// parseEnvelope parses data as envelope, returning an error if it is none so callers can fall
+// back to legacy decryption. Legacy blobs start with a random IV or nonce, which may form a
+// valid header by chance: such a blob is misclassified as envelope and fails to decrypt.
+// As the magic, version, algorithm and cipher mode have to match, the chance is below 2^-44.
+func parseEnvelope(data []byte) (*Envelope, error) {
+	if !isEnvelope(data) {
+		return nil, fmt.Errorf("Data is not an envelope")
+	}
+
+	reader := envelopeReader{data: data, index: len(envelopeMagic)}
+
+	envelope := Envelope{
+		Version: reader.byte(),
+	}
+	if reader.err == nil && envelope.Version != envelopeVersion1 && envelope.Version != envelopeVersion2 {
+		return nil, fmt.Errorf("Unsupported envelope version %d", envelope.Version)
+	}
+
+	envelope.Algorithm = CipherAlgorithm(reader.byte())
+	envelope.CipherMode = CipherMode(reader.byte())
+	envelope.KeyId = string(reader.bytes(int(reader.byte())))
+	envelope.Nonce = reader.bytes(int(reader.byte()))
+	envelope.TagSize = int(reader.byte())
+
+	if envelope.Version >= envelopeVersion2 {
+		envelope.Kdf = Kdf(reader.byte())
+		for i := range envelope.KdfParams {
+			envelope.KdfParams[i] = reader.uint32()
+		}
+		envelope.Salt = reader.bytes(int(reader.byte()))
+	}
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	if envelope.Algorithm < AES128 || envelope.Algorithm > AES256 {
+		return nil, fmt.Errorf("Unsupported algorithm %d", envelope.Algorithm)
+	}
+	if envelope.CipherMode != CBC && envelope.CipherMode != GCM {
+		return nil, fmt.Errorf("Unsupported cipher mode %d", envelope.CipherMode)
+	}
+
+	envelope.CipherText = data[reader.index:]
+
+	return &envelope, nil
+}
+
+// envelopeReader reads an envelope header, recording an error instead of reading beyond data
+type envelopeReader struct {
+	data  []byte
+	index int
+	err   error
+}
+
+func (reader *envelopeReader) bytes(n int) []byte {
+	if reader.err != nil {
+		return nil
+	}
+
+	if reader.index+n > len(reader.data) {
+		reader.err = fmt.Errorf("Invalid envelope length %d", len(reader.data))
+		return nil
+	}
+
+	b := reader.data[reader.index : reader.index+n]
+	reader.index += n
+
+	return b
+}
+
+func (reader *envelopeReader) byte() byte {
+	b := reader.bytes(1)
+	if b == nil {
+		return 0
+	}
+
+	return b[0]
+}
+
+func (reader *envelopeReader) uint32() uint32 {
+	b := reader.bytes(4)
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(b)
+}
+
+// decrypt decrypts the envelope with key, aad being the additional data passed when encrypting
+func (envelope *Envelope) decrypt(key []byte, aad []byte) ([]byte, error) {
+	algorithm, err := cipherAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	if algorithm != envelope.Algorithm {
+		return nil, fmt.Errorf("Key size %d does not match algorithm %d", len(key), envelope.Algorithm)
+	}
+
+	if envelope.CipherMode == GCM {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		gcm, err := newGcm(block, len(envelope.Nonce), envelope.TagSize)
+		if err != nil {
+			return nil, err
+		}
+
+		return gcm.Open(nil, envelope.Nonce, envelope.CipherText, envelope.additionalData(aad))
+	}
+
+	if len(aad) > 0 {
+		return nil, errAadRequiresGcm
+	}
+
+	if envelope.TagSize != sha256.Size || len(envelope.CipherText) < envelope.TagSize {
+		return nil, fmt.Errorf("Invalid CBC envelope tag")
+	}
+
+	encryptionKey, macKey := cbcEnvelopeKeys(key)
+
+	// verify the tag before decrypting, so no padding errors are revealed for forged data
+	encrypted, tag := envelope.CipherText[:len(envelope.CipherText)-envelope.TagSize], envelope.CipherText[len(envelope.CipherText)-envelope.TagSize:]
+	if !hmac.Equal(tag, envelope.mac(macKey, encrypted)) {
+		return nil, fmt.Errorf("Message authentication failed")
+	}
+
+	block, err := aes.NewCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plainText, err := DecryptCbc(block, encrypted, envelope.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(plainText), nil
+}
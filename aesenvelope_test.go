@@ -0,0 +1,121 @@
+package utilities
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+func testKey(t *testing.T, size int) []byte {
+	t.Helper()
+
+	key := make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestEnvelopeRoundTrip(t *testing.T) {
+	for _, cipherMode := range []CipherMode{CBC, GCM} {
+		for _, keySize := range []int{16, 24, 32} {
+			crypto := AesCrypto{CipherMode: cipherMode}
+			key := testKey(t, keySize)
+
+			for _, plainText := range []string{"", "secret", "exactly 16 bytes"} {
+				cipherText, err := crypto.EncryptEnvelope([]byte(plainText), key, "key-1")
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				envelope, err := ParseEnvelope(cipherText)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if envelope.KeyId != "key-1" || envelope.CipherMode != cipherMode {
+					t.Fatalf("parsed key ID '%s', mode %d", envelope.KeyId, envelope.CipherMode)
+				}
+
+				// the mode is read from the envelope
+				decrypted, err := AesCrypto{}.Decrypt(cipherText, key, "")
+				if err != nil {
+					t.Fatalf("mode %d, key size %d: %s", cipherMode, keySize, err)
+				}
+				if decrypted != plainText {
+					t.Fatalf("decrypted '%s', expected '%s'", decrypted, plainText)
+				}
+			}
+		}
+	}
+}
+
+func TestEnvelopeWrongKey(t *testing.T) {
+	for _, cipherMode := range []CipherMode{CBC, GCM} {
+		crypto := AesCrypto{CipherMode: cipherMode}
+
+		cipherText, err := crypto.EncryptEnvelope([]byte("secret"), testKey(t, 32), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// an unauthenticated CBC envelope would decrypt with about 1 in 256 wrong keys
+		for i := 0; i < 1000; i++ {
+			if _, err = crypto.Decrypt(cipherText, testKey(t, 32), ""); err == nil {
+				t.Fatalf("mode %d: envelope decrypted with wrong key", cipherMode)
+			}
+		}
+
+		if _, err = crypto.Decrypt(cipherText, testKey(t, 16), ""); err == nil {
+			t.Fatalf("mode %d: envelope decrypted with key of other size", cipherMode)
+		}
+	}
+}
+
+func TestEnvelopeTampered(t *testing.T) {
+	for _, cipherMode := range []CipherMode{CBC, GCM} {
+		crypto := AesCrypto{CipherMode: cipherMode}
+		key := testKey(t, 32)
+
+		cipherText, err := crypto.EncryptEnvelope([]byte("secret"), key, "key-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := base64.StdEncoding.DecodeString(cipherText)
+
+		for i := range data {
+			tampered := append([]byte{}, data...)
+			tampered[i] ^= 1
+
+			if _, err = crypto.Decrypt(base64.StdEncoding.EncodeToString(tampered), key, ""); err == nil {
+				t.Fatalf("mode %d: envelope with byte %d flipped decrypted", cipherMode, i)
+			}
+		}
+	}
+}
+
+func TestEnvelopeAadMismatch(t *testing.T) {
+	crypto := AesCrypto{CipherMode: GCM}
+	key := testKey(t, 32)
+
+	cipherText, err := crypto.EncryptEnvelopeWithAad([]byte("secret"), key, "", []byte("tenant-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = crypto.DecryptWithAad(cipherText, key, "", []byte("tenant-2")); err == nil {
+		t.Fatal("envelope decrypted with other aad")
+	}
+	if _, err = crypto.Decrypt(cipherText, key, ""); err == nil {
+		t.Fatal("envelope decrypted without aad")
+	}
+
+	decrypted, err := crypto.DecryptWithAad(cipherText, key, "", []byte("tenant-1"))
+	if err != nil || decrypted != "secret" {
+		t.Fatalf("decrypted '%s', error %v", decrypted, err)
+	}
+
+	if _, err = (AesCrypto{CipherMode: CBC}).EncryptEnvelopeWithAad([]byte("secret"), key, "", []byte("tenant-1")); err == nil {
+		t.Fatal("CBC envelope encrypted with aad")
+	}
+}
@@ -0,0 +1,221 @@
+package utilities
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"io"
+)
+
+// Kdf is the function deriving a key from a passphrase
+type Kdf byte
+
+const (
+	KdfNone Kdf = iota
+	KdfPbkdf2
+	KdfScrypt
+	KdfArgon2id
+)
+
+const (
+	defaultKdfSaltSize         = 16
+	defaultPbkdf2Iterations    = 600000
+	defaultScryptN             = 32768
+	defaultScryptR             = 8
+	defaultScryptP             = 1
+	defaultArgon2idTime        = 1
+	defaultArgon2idMemory      = 64 * 1024
+	defaultArgon2idParallelism = 4
+)
+
+// maximum cost parameters, guarding against envelopes crafted to exhaust memory or CPU
+const (
+	maxKdfMemory          uint32 = 1024 * 1024 // KiB
+	maxKdfParallelism     uint32 = 16
+	maxPbkdf2Iterations   uint32 = 10000000
+	maxArgon2idIterations uint32 = 10
+)
+
+// KdfOptions holds the key derivation function and its cost parameters, defaults applying to zero values:
+//
+//	KdfPbkdf2    Iterations (600000, at most 10000000), using HMAC-SHA256
+//	KdfScrypt    N (32768), R (8) and Parallelism (1, at most 16), using at most 1 GiB (128 * N * R bytes)
+//	KdfArgon2id  Iterations (1, at most 10), Memory in KiB (65536, at most 1 GiB) and Parallelism (4, at most 16)
+//
+// KeySize is 16, 24 or 32 bytes (default) for AES-128, AES-192 or AES-256.
+// A random salt of SaltSize (default 16) bytes is generated per message.
+type KdfOptions struct {
+	Kdf         Kdf
+	Iterations  uint32
+	Memory      uint32
+	N           uint32
+	R           uint32
+	Parallelism uint32
+	KeySize     int
+	SaltSize    int
+}
+
+// params returns the kdf parameters as stored in an envelope
+func (options *KdfOptions) params() ([3]uint32, error) {
+	orDefault := func(value uint32, defaultValue uint32) uint32 {
+		if value == 0 {
+			return defaultValue
+		}
+		return value
+	}
+
+	switch options.Kdf {
+	case KdfPbkdf2:
+		return [3]uint32{orDefault(options.Iterations, defaultPbkdf2Iterations), 0, 0}, nil
+	case KdfScrypt:
+		return [3]uint32{orDefault(options.N, defaultScryptN), orDefault(options.R, defaultScryptR), orDefault(options.Parallelism, defaultScryptP)}, nil
+	case KdfArgon2id:
+		return [3]uint32{orDefault(options.Iterations, defaultArgon2idTime), orDefault(options.Memory, defaultArgon2idMemory), orDefault(options.Parallelism, defaultArgon2idParallelism)}, nil
+	}
+
+	return [3]uint32{}, fmt.Errorf("Unsupported key derivation function %d", options.Kdf)
+}
+
+// deriveKey derives a key of keySize bytes from passphrase
+func deriveKey(passphrase string, kdf Kdf, params [3]uint32, salt []byte, keySize int) ([]byte, error) {
+	switch kdf {
+	case KdfPbkdf2:
+		if params[0] == 0 || params[0] > maxPbkdf2Iterations {
+			return nil, fmt.Errorf("Invalid PBKDF2 iterations %d", params[0])
+		}
+		return pbkdf2.Key([]byte(passphrase), salt, int(params[0]), keySize, sha256.New), nil
+	case KdfScrypt:
+		// memory used is 128 * N * r bytes
+		if uint64(params[0])*uint64(params[1])/8 > uint64(maxKdfMemory) || params[2] == 0 || params[2] > maxKdfParallelism {
+			return nil, fmt.Errorf("Invalid scrypt parameters")
+		}
+		return scrypt.Key([]byte(passphrase), salt, int(params[0]), int(params[1]), int(params[2]), keySize)
+	case KdfArgon2id:
+		if params[0] == 0 || params[0] > maxArgon2idIterations || params[1] > maxKdfMemory || params[2] == 0 || params[2] > maxKdfParallelism {
+			return nil, fmt.Errorf("Invalid Argon2id parameters")
+		}
+		return argon2.IDKey([]byte(passphrase), salt, params[0], params[1], uint8(params[2]), uint32(keySize)), nil
+	}
+
+	return nil, fmt.Errorf("Unsupported key derivation function %d", kdf)
+}
+
+// EncryptWithPassphrase encrypts plainTextBytes using CipherMode with a key derived from passphrase
+// as set by options (default Argon2id), storing the kdf, its parameters and the salt in the envelope
+func (crypto AesCrypto) EncryptWithPassphrase(plainTextBytes []byte, passphrase string, options *KdfOptions) (string, error) {
+	return crypto.EncryptWithPassphraseWithAad(plainTextBytes, passphrase, options, nil)
+}
+
+// EncryptWithPassphraseWithAad encrypts like EncryptWithPassphrase, authenticating aad as additional
+// data, see EncryptEnvelopeWithAad. Only GCM mode supports aad.
+func (crypto AesCrypto) EncryptWithPassphraseWithAad(plainTextBytes []byte, passphrase string, options *KdfOptions, aad []byte) (string, error) {
+	if options == nil {
+		options = &KdfOptions{Kdf: KdfArgon2id}
+	}
+
+	params, err := options.params()
+	if err != nil {
+		return "", err
+	}
+
+	keySize := options.KeySize
+	if keySize == 0 {
+		keySize = 32
+	}
+
+	saltSize := options.SaltSize
+	if saltSize == 0 {
+		saltSize = defaultKdfSaltSize
+	}
+	if saltSize > 255 {
+		return "", fmt.Errorf("Salt size exceeds 255 bytes")
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err = io.ReadFull(rand.Reader, salt); err != nil {
+		return "", err
+	}
+
+	key, err := deriveKey(passphrase, options.Kdf, params, salt, keySize)
+	if err != nil {
+		return "", err
+	}
+
+	envelope := Envelope{
+		Version:   envelopeVersion2,
+		Kdf:       options.Kdf,
+		KdfParams: params,
+		Salt:      salt,
+	}
+
+	return crypto.seal(&envelope, plainTextBytes, key, aad)
+}
+
+// DecryptWithPassphrase decrypts cipherText as returned by EncryptWithPassphrase or,
+// lacking an envelope, a legacy blob using CipherMode and LegacyKey(passphrase)
+func (crypto AesCrypto) DecryptWithPassphrase(cipherText string, passphrase string) (string, error) {
+	return crypto.DecryptWithPassphraseWithAad(cipherText, passphrase, nil)
+}
+
+// DecryptWithPassphraseWithAad decrypts like DecryptWithPassphrase, cipherText being encrypted with aad as additional data
+func (crypto AesCrypto) DecryptWithPassphraseWithAad(cipherText string, passphrase string, aad []byte) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return "", err
+	}
+
+	envelope, err := parseEnvelope(data)
+	if err != nil {
+		return crypto.decryptLegacy(data, LegacyKey(passphrase), "", aad)
+	}
+
+	plainTextBytes, err := envelope.decryptWithPassphrase(passphrase, aad)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plainTextBytes), nil
+}
+
+func (envelope *Envelope) decryptWithPassphrase(passphrase string, aad []byte) ([]byte, error) {
+	if envelope.Kdf == KdfNone {
+		return nil, fmt.Errorf("Envelope holds no key derivation")
+	}
+
+	var keySize int
+	switch envelope.Algorithm {
+	case AES128:
+		keySize = 16
+	case AES192:
+		keySize = 24
+	default:
+		keySize = 32
+	}
+
+	key, err := deriveKey(passphrase, envelope.Kdf, envelope.KdfParams, envelope.Salt, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return envelope.decrypt(key, aad)
+}
+
+// EncryptWithKdf encrypts b in GCM mode with a key derived from cipherKey as set by options
+// (default Argon2id), see EncryptWithPassphrase. Decrypt decrypts the result.
+func EncryptWithKdf(b []byte, cipherKey string, options *KdfOptions) (string, error) {
+	return EncryptWithKdfWithAad(b, cipherKey, options, nil)
+}
+
+// EncryptWithKdfWithAad encrypts like EncryptWithKdf, authenticating aad as additional data.
+// DecryptWithAad decrypts the result.
+func EncryptWithKdfWithAad(b []byte, cipherKey string, options *KdfOptions, aad []byte) (string, error) {
+	a := AesCrypto{
+		CipherMode: GCM,
+	}
+
+	return a.EncryptWithPassphraseWithAad(b, cipherKey, options, aad)
+}
@@ -0,0 +1,89 @@
+package utilities
+
+import (
+	"testing"
+)
+
+// testKdfOptions keeps key derivation cheap in tests
+var testKdfOptions = &KdfOptions{Kdf: KdfPbkdf2, Iterations: 1000}
+
+func TestPassphrase(t *testing.T) {
+	cipherText, err := EncryptWithKdf([]byte("secret"), "passphrase", testKdfOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := Decrypt([]byte(cipherText), "passphrase")
+	if err != nil || decrypted != "secret" {
+		t.Fatalf("decrypted '%s', error %v", decrypted, err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if _, err = Decrypt([]byte(cipherText), "wrong passphrase"); err == nil {
+			t.Fatal("envelope decrypted with wrong passphrase")
+		}
+	}
+}
+
+func TestPassphraseAad(t *testing.T) {
+	cipherText, err := EncryptWithKdfWithAad([]byte("secret"), "passphrase", testKdfOptions, []byte("tenant-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = DecryptWithAad([]byte(cipherText), "passphrase", []byte("tenant-2")); err == nil {
+		t.Fatal("envelope decrypted with other aad")
+	}
+	if _, err = Decrypt([]byte(cipherText), "passphrase"); err == nil {
+		t.Fatal("envelope decrypted without aad")
+	}
+	decrypted, err := DecryptWithAad([]byte(cipherText), "passphrase", []byte("tenant-1"))
+	if err != nil || decrypted != "secret" {
+		t.Fatalf("decrypted '%s', error %v", decrypted, err)
+	}
+}
+
+func TestPassphraseKdfs(t *testing.T) {
+	for _, options := range []*KdfOptions{
+		{Kdf: KdfPbkdf2, Iterations: 1000, KeySize: 16},
+		{Kdf: KdfScrypt, N: 1024, R: 8, Parallelism: 1},
+		{Kdf: KdfArgon2id, Iterations: 1, Memory: 1024, Parallelism: 1, SaltSize: 32},
+	} {
+		cipherText, err := EncryptWithKdf([]byte("secret"), "passphrase", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		envelope, err := ParseEnvelope(cipherText)
+		if err != nil || envelope.Kdf != options.Kdf {
+			t.Fatalf("kdf %d: parsed %+v, error %v", options.Kdf, envelope, err)
+		}
+
+		decrypted, err := Decrypt([]byte(cipherText), "passphrase")
+		if err != nil || decrypted != "secret" {
+			t.Fatalf("kdf %d: decrypted '%s', error %v", options.Kdf, decrypted, err)
+		}
+	}
+}
+
+func TestPassphraseKdfLimits(t *testing.T) {
+	for _, envelope := range []Envelope{
+		{Kdf: KdfPbkdf2, KdfParams: [3]uint32{maxPbkdf2Iterations + 1}},
+		{Kdf: KdfScrypt, KdfParams: [3]uint32{1 << 30, 8, 1}},
+		{Kdf: KdfScrypt, KdfParams: [3]uint32{1024, 8, maxKdfParallelism + 1}},
+		{Kdf: KdfArgon2id, KdfParams: [3]uint32{maxArgon2idIterations + 1, 1024, 1}},
+		{Kdf: KdfArgon2id, KdfParams: [3]uint32{1, maxKdfMemory + 1, 1}},
+		{Kdf: KdfArgon2id, KdfParams: [3]uint32{1, 1024, maxKdfParallelism + 1}},
+	} {
+		envelope.Version = envelopeVersion2
+		envelope.Algorithm = AES256
+		envelope.CipherMode = GCM
+		envelope.Nonce = make([]byte, gcmStandardNonceSize)
+		envelope.TagSize = gcmStandardTagSize
+		envelope.Salt = make([]byte, defaultKdfSaltSize)
+		envelope.CipherText = make([]byte, gcmStandardTagSize)
+
+		if _, err := (AesCrypto{}).DecryptWithPassphrase(envelope.String(), "passphrase"); err == nil {
+			t.Fatalf("envelope with kdf %d parameters %v decrypted", envelope.Kdf, envelope.KdfParams)
+		}
+	}
+}
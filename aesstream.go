@@ -0,0 +1,296 @@
+package utilities
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+const (
+	streamVersion1         byte = 1
+	streamNoncePrefixSize       = 7 // nonce: prefix | 4 byte counter | last chunk flag
+	defaultStreamChunkSize      = 64 * 1024
+	maxStreamChunkSize          = 16 * 1024 * 1024
+)
+
+var streamMagic = []byte("AEVS")
+
+// StreamOptions holds the options of Encrypter and Decrypter. ChunkSize (default 64 KiB) is the
+// size of the plain text chunks sealed separately, Aad additional data authenticated with each chunk.
+type StreamOptions struct {
+	ChunkSize int
+	Aad       []byte
+}
+
+func (options *StreamOptions) chunkSize() int {
+	if options == nil || options.ChunkSize == 0 {
+		return defaultStreamChunkSize
+	}
+
+	return options.ChunkSize
+}
+
+func (options *StreamOptions) aad() []byte {
+	if options == nil {
+		return nil
+	}
+
+	return options.Aad
+}
+
+// streamAead holds the state shared by Encrypter and Decrypter
+type streamAead struct {
+	gcm         cipher.AEAD
+	additional  []byte // header followed by aad
+	noncePrefix []byte
+	chunkSize   int
+	counter     uint32
+	done        bool
+}
+
+func newStreamAead(key []byte, header []byte, noncePrefix []byte, chunkSize int, aad []byte) (*streamAead, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamAead{
+		gcm:         gcm,
+		additional:  append(append([]byte{}, header...), aad...),
+		noncePrefix: noncePrefix,
+		chunkSize:   chunkSize,
+	}, nil
+}
+
+// nonce returns the nonce of the next chunk, advancing the counter
+func (stream *streamAead) nonce(last bool) ([]byte, error) {
+	if stream.counter == math.MaxUint32 {
+		return nil, fmt.Errorf("Stream exceeds maximum number of chunks")
+	}
+
+	nonce := append([]byte{}, stream.noncePrefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, stream.counter)
+	if last {
+		nonce = append(nonce, 1)
+	} else {
+		nonce = append(nonce, 0)
+	}
+
+	stream.counter++
+
+	return nonce, nil
+}
+
+// Encrypter encrypts everything written to it in chunks using AES-GCM, writing raw binary to an
+// io.Writer. Following the STREAM construction, each chunk has its own nonce derived from a random
+// prefix, a counter and a last chunk flag, so truncation and reordering are detected by Decrypter.
+// Close must be called to write the last chunk.
+type Encrypter struct {
+	writer io.Writer
+	stream *streamAead
+	buffer []byte
+}
+
+// NewEncrypter returns an Encrypter writing to writer, key being 16, 24 or 32 bytes.
+// The stream header, holding the chunk size and nonce prefix, is written immediately.
+func NewEncrypter(writer io.Writer, key []byte, options *StreamOptions) (*Encrypter, error) {
+	algorithm, err := cipherAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	chunkSize := options.chunkSize()
+	if chunkSize < 1 || chunkSize > maxStreamChunkSize {
+		return nil, fmt.Errorf("Invalid chunk size %d", chunkSize)
+	}
+
+	noncePrefix := make([]byte, streamNoncePrefixSize)
+	if _, err = io.ReadFull(rand.Reader, noncePrefix); err != nil {
+		return nil, err
+	}
+
+	// magic | version | algorithm | chunk size | nonce prefix
+	header := append([]byte{}, streamMagic...)
+	header = append(header, streamVersion1, byte(algorithm))
+	header = binary.BigEndian.AppendUint32(header, uint32(chunkSize))
+	header = append(header, noncePrefix...)
+
+	stream, err := newStreamAead(key, header, noncePrefix, chunkSize, options.aad())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &Encrypter{
+		writer: writer,
+		stream: stream,
+	}, nil
+}
+
+// Write implements io.Writer
+func (encrypter *Encrypter) Write(p []byte) (int, error) {
+	if encrypter.stream.done {
+		return 0, fmt.Errorf("Encrypter is closed")
+	}
+
+	encrypter.buffer = append(encrypter.buffer, p...)
+
+	// keep the last chunk buffered until Close, as it is sealed with the last chunk flag
+	for len(encrypter.buffer) > encrypter.stream.chunkSize {
+		err := encrypter.seal(encrypter.buffer[:encrypter.stream.chunkSize], false)
+		if err != nil {
+			return 0, err
+		}
+		encrypter.buffer = encrypter.buffer[encrypter.stream.chunkSize:]
+	}
+
+	return len(p), nil
+}
+
+func (encrypter *Encrypter) seal(chunk []byte, last bool) error {
+	nonce, err := encrypter.stream.nonce(last)
+	if err != nil {
+		return err
+	}
+
+	_, err = encrypter.writer.Write(encrypter.stream.gcm.Seal(nil, nonce, chunk, encrypter.stream.additional))
+
+	return err
+}
+
+// Close writes the last chunk. It does not close the underlying io.Writer.
+func (encrypter *Encrypter) Close() error {
+	if encrypter.stream.done {
+		return nil
+	}
+
+	err := encrypter.seal(encrypter.buffer, true)
+	if err != nil {
+		return err
+	}
+
+	encrypter.buffer = nil
+	encrypter.stream.done = true
+
+	return nil
+}
+
+// Decrypter decrypts a stream written by Encrypter, returning an error if the stream
+// has been tampered with, truncated or reordered
+type Decrypter struct {
+	reader *bufio.Reader
+	stream *streamAead
+	chunk  []byte // cipher text buffer
+	plain  []byte // decrypted plain text not yet read
+}
+
+// NewDecrypter returns a Decrypter reading from reader, reading the stream header immediately.
+// Key and options.Aad should match those passed to NewEncrypter.
+func NewDecrypter(reader io.Reader, key []byte, options *StreamOptions) (*Decrypter, error) {
+	bufferedReader := bufio.NewReader(reader)
+
+	header := make([]byte, len(streamMagic)+2+4+streamNoncePrefixSize)
+	if _, err := io.ReadFull(bufferedReader, header); err != nil {
+		return nil, fmt.Errorf("Invalid stream header: %s", err.Error())
+	}
+
+	if !bytes.HasPrefix(header, streamMagic) {
+		return nil, fmt.Errorf("Data is not an encrypted stream")
+	}
+
+	index := len(streamMagic)
+	if header[index] != streamVersion1 {
+		return nil, fmt.Errorf("Unsupported stream version %d", header[index])
+	}
+
+	algorithm, err := cipherAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+	if CipherAlgorithm(header[index+1]) != algorithm {
+		return nil, fmt.Errorf("Key size %d does not match algorithm %d", len(key), header[index+1])
+	}
+
+	chunkSize := int(binary.BigEndian.Uint32(header[index+2:]))
+	if chunkSize < 1 || chunkSize > maxStreamChunkSize {
+		return nil, fmt.Errorf("Invalid chunk size %d", chunkSize)
+	}
+
+	stream, err := newStreamAead(key, header, header[index+6:], chunkSize, options.aad())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Decrypter{
+		reader: bufferedReader,
+		stream: stream,
+		chunk:  make([]byte, chunkSize+stream.gcm.Overhead()),
+	}, nil
+}
+
+// Read implements io.Reader
+func (decrypter *Decrypter) Read(p []byte) (int, error) {
+	for len(decrypter.plain) == 0 {
+		if decrypter.stream.done {
+			return 0, io.EOF
+		}
+
+		err := decrypter.open()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, decrypter.plain)
+	decrypter.plain = decrypter.plain[n:]
+
+	return n, nil
+}
+
+// open reads and decrypts the next chunk, the last one being followed by the end of the stream
+func (decrypter *Decrypter) open() error {
+	n, err := io.ReadFull(decrypter.reader, decrypter.chunk)
+	last := false
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		last = true
+	} else if err != nil {
+		return err
+	} else if _, err = decrypter.reader.Peek(1); errors.Is(err, io.EOF) {
+		last = true
+	} else if err != nil {
+		return err
+	}
+
+	nonce, err := decrypter.stream.nonce(last)
+	if err != nil {
+		return err
+	}
+
+	plain, err := decrypter.stream.gcm.Open(nil, nonce, decrypter.chunk[:n], decrypter.stream.additional)
+	if err != nil {
+		if last {
+			return fmt.Errorf("Stream is invalid or truncated: %s", err.Error())
+		}
+		return err
+	}
+
+	decrypter.plain = plain
+	decrypter.stream.done = last
+
+	return nil
+}
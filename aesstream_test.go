@@ -0,0 +1,168 @@
+package utilities
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+const testStreamChunkSize = 16
+
+func encryptStream(t *testing.T, key []byte, plainText []byte, options *StreamOptions) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	encrypter, err := NewEncrypter(&buffer, key, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = encrypter.Write(plainText); err != nil {
+		t.Fatal(err)
+	}
+	if err = encrypter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buffer.Bytes()
+}
+
+func decryptStream(key []byte, cipherText []byte, options *StreamOptions) ([]byte, error) {
+	decrypter, err := NewDecrypter(bytes.NewReader(cipherText), key, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(decrypter)
+}
+
+// streamChunks splits an encrypted stream into its header and chunks
+func streamChunks(cipherText []byte, chunkSize int) ([]byte, [][]byte) {
+	headerSize := len(streamMagic) + 2 + 4 + streamNoncePrefixSize
+	header, rest := cipherText[:headerSize], cipherText[headerSize:]
+
+	var chunks [][]byte
+	for len(rest) > 0 {
+		n := min(chunkSize+gcmStandardTagSize, len(rest))
+		chunks = append(chunks, rest[:n])
+		rest = rest[n:]
+	}
+
+	return header, chunks
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	key := testKey(t, 32)
+	options := &StreamOptions{ChunkSize: testStreamChunkSize}
+
+	for _, size := range []int{0, 1, testStreamChunkSize - 1, testStreamChunkSize, testStreamChunkSize + 1, 3 * testStreamChunkSize, 1000} {
+		plainText := bytes.Repeat([]byte{'x'}, size)
+
+		decrypted, err := decryptStream(key, encryptStream(t, key, plainText, options), options)
+		if err != nil {
+			t.Fatalf("size %d: %s", size, err)
+		}
+		if !bytes.Equal(decrypted, plainText) {
+			t.Fatalf("size %d: decrypted %d bytes", size, len(decrypted))
+		}
+	}
+}
+
+func TestStreamEmpty(t *testing.T) {
+	key := testKey(t, 16)
+
+	cipherText := encryptStream(t, key, nil, nil)
+
+	decrypted, err := decryptStream(key, cipherText, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decrypted) != 0 {
+		t.Fatalf("decrypted %d bytes from empty stream", len(decrypted))
+	}
+
+	// the last chunk of an empty stream is still required
+	header, _ := streamChunks(cipherText, defaultStreamChunkSize)
+	if _, err = decryptStream(key, header, nil); err == nil {
+		t.Fatal("stream without chunks decrypted")
+	}
+}
+
+func TestStreamTruncatedAtChunkBoundary(t *testing.T) {
+	key := testKey(t, 32)
+	options := &StreamOptions{ChunkSize: testStreamChunkSize}
+
+	cipherText := encryptStream(t, key, bytes.Repeat([]byte{'x'}, 3*testStreamChunkSize), options)
+	header, chunks := streamChunks(cipherText, testStreamChunkSize)
+	if len(chunks) != 3 {
+		t.Fatalf("got %d chunks, expected 3", len(chunks))
+	}
+
+	for n := 0; n < len(chunks); n++ {
+		truncated := append([]byte{}, header...)
+		for _, chunk := range chunks[:n] {
+			truncated = append(truncated, chunk...)
+		}
+
+		if _, err := decryptStream(key, truncated, options); err == nil {
+			t.Fatalf("stream truncated after %d chunks decrypted", n)
+		}
+	}
+}
+
+func TestStreamReorderedChunks(t *testing.T) {
+	key := testKey(t, 32)
+	options := &StreamOptions{ChunkSize: testStreamChunkSize}
+
+	cipherText := encryptStream(t, key, bytes.Repeat([]byte{'x'}, 3*testStreamChunkSize), options)
+	header, chunks := streamChunks(cipherText, testStreamChunkSize)
+
+	reordered := append([]byte{}, header...)
+	reordered = append(reordered, chunks[1]...)
+	reordered = append(reordered, chunks[0]...)
+	reordered = append(reordered, chunks[2]...)
+
+	if _, err := decryptStream(key, reordered, options); err == nil {
+		t.Fatal("stream with reordered chunks decrypted")
+	}
+}
+
+func TestStreamAppendedData(t *testing.T) {
+	key := testKey(t, 32)
+	options := &StreamOptions{ChunkSize: testStreamChunkSize}
+
+	first := encryptStream(t, key, []byte("first"), options)
+	_, chunks := streamChunks(encryptStream(t, key, []byte("second"), options), testStreamChunkSize)
+
+	if _, err := decryptStream(key, append(first, chunks[0]...), options); err == nil {
+		t.Fatal("stream with appended chunk decrypted")
+	}
+}
+
+func TestStreamAadMismatch(t *testing.T) {
+	key := testKey(t, 32)
+
+	cipherText := encryptStream(t, key, []byte("export"), &StreamOptions{Aad: []byte("tenant-1")})
+
+	if _, err := decryptStream(key, cipherText, &StreamOptions{Aad: []byte("tenant-2")}); err == nil {
+		t.Fatal("stream decrypted with other aad")
+	}
+	if _, err := decryptStream(key, cipherText, nil); err == nil {
+		t.Fatal("stream decrypted without aad")
+	}
+
+	decrypted, err := decryptStream(key, cipherText, &StreamOptions{Aad: []byte("tenant-1")})
+	if err != nil || string(decrypted) != "export" {
+		t.Fatalf("decrypted '%s', error %v", decrypted, err)
+	}
+}
+
+func TestStreamWrongKey(t *testing.T) {
+	cipherText := encryptStream(t, testKey(t, 32), []byte("export"), nil)
+
+	if _, err := decryptStream(testKey(t, 32), cipherText, nil); err == nil {
+		t.Fatal("stream decrypted with wrong key")
+	}
+	if _, err := decryptStream(testKey(t, 16), cipherText, nil); err == nil {
+		t.Fatal("stream decrypted with key of other size")
+	}
+}
@@ -0,0 +1,290 @@
+package utilities
+
+import (
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"fmt"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"math/big"
+	"reflect"
+	"time"
+)
+
+const defaultBigQueryTag string = "bigquery"
+
+// BigQueryOptions holds the options used when mapping structs to BigQuery.
+// Fields are named after Tag (default "bigquery") or else their field name.
+// Like bigquery.InferSchema, fields are REQUIRED unless they are pointers,
+// bigquery.Null types, repeated or tagged with the nullable option.
+type BigQueryOptions struct {
+	Tag *string
+}
+
+func (options *BigQueryOptions) tag() string {
+	if options == nil || options.Tag == nil {
+		return defaultBigQueryTag
+	}
+
+	return *options.Tag
+}
+
+var (
+	bytesType  = reflect.TypeOf([]byte{})
+	bigRatType = reflect.TypeOf(big.Rat{})
+)
+
+var bigQueryNullFieldTypes = map[reflect.Type]bigquery.FieldType{
+	reflect.TypeOf(bigquery.NullString{}):    bigquery.StringFieldType,
+	reflect.TypeOf(bigquery.NullInt64{}):     bigquery.IntegerFieldType,
+	reflect.TypeOf(bigquery.NullFloat64{}):   bigquery.FloatFieldType,
+	reflect.TypeOf(bigquery.NullBool{}):      bigquery.BooleanFieldType,
+	reflect.TypeOf(bigquery.NullTimestamp{}): bigquery.TimestampFieldType,
+	reflect.TypeOf(bigquery.NullDate{}):      bigquery.DateFieldType,
+	reflect.TypeOf(bigquery.NullTime{}):      bigquery.TimeFieldType,
+	reflect.TypeOf(bigquery.NullDateTime{}):  bigquery.DateTimeFieldType,
+	reflect.TypeOf(bigquery.NullGeography{}): bigquery.GeographyFieldType,
+	reflect.TypeOf(bigquery.NullJSON{}):      bigquery.JSONFieldType,
+}
+
+// StructToBigQuerySchema derives a bigquery.Schema from model, a (pointer to a) struct.
+// civil.Date, civil.Time, civil.DateTime, time.Time and bigquery.Null types map to their
+// BigQuery counterparts, nested structs to RECORD and slices to REPEATED fields.
+// Fields of embedded structs are promoted unless the embedded struct is named in its tag.
+func StructToBigQuerySchema(model interface{}, options *BigQueryOptions) (bigquery.Schema, *errortools.Error) {
+	t := reflect.TypeOf(model)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errortools.ErrorMessage("The interface is not a (pointer to a) struct.")
+	}
+
+	schema, err := bigQuerySchema(t, options.tag(), map[reflect.Type]bool{})
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	return schema, nil
+}
+
+func bigQuerySchema(t reflect.Type, tag string, visited map[reflect.Type]bool) (bigquery.Schema, error) {
+	if visited[t] {
+		return nil, fmt.Errorf("recursive type %s is not supported", t)
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	schema := bigquery.Schema{}
+
+	for _, field := range structFields(t, tag, true, promoteEmbedded) {
+		fieldSchema, err := bigQueryFieldSchema(field.name, field.field.Type, tag, visited)
+		if err != nil {
+			return nil, fmt.Errorf("field '%s': %s", field.fieldName, err.Error())
+		}
+
+		if field.options.Contains("nullable") || isPromotedFromPointer(t, field.index) {
+			fieldSchema.Required = false
+		}
+
+		schema = append(schema, fieldSchema)
+	}
+
+	return schema, nil
+}
+
+// isPromotedFromPointer reports whether the field of t with index is promoted from an embedded pointer,
+// so it has no value if the pointer is nil
+func isPromotedFromPointer(t reflect.Type, index []int) bool {
+	for _, i := range index[:len(index)-1] {
+		t = t.Field(i).Type
+		if t.Kind() == reflect.Ptr {
+			return true
+		}
+	}
+
+	return false
+}
+
+func bigQueryFieldSchema(name string, t reflect.Type, tag string, visited map[reflect.Type]bool) (*bigquery.FieldSchema, error) {
+	fieldSchema := bigquery.FieldSchema{
+		Name:     name,
+		Required: true,
+	}
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+		fieldSchema.Required = false
+	}
+
+	if t != bytesType && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		elementSchema, err := bigQueryFieldSchema(name, t.Elem(), tag, visited)
+		if err != nil {
+			return nil, err
+		}
+		if elementSchema.Repeated {
+			return nil, fmt.Errorf("nested repeated type %s is not supported", t)
+		}
+
+		elementSchema.Repeated = true
+		elementSchema.Required = false
+
+		return elementSchema, nil
+	}
+
+	if fieldType, ok := bigQueryNullFieldTypes[t]; ok {
+		fieldSchema.Type = fieldType
+		fieldSchema.Required = false
+
+		return &fieldSchema, nil
+	}
+
+	switch t {
+	case bytesType:
+		fieldSchema.Type = bigquery.BytesFieldType
+	case timeType:
+		fieldSchema.Type = bigquery.TimestampFieldType
+	case civilDateType:
+		fieldSchema.Type = bigquery.DateFieldType
+	case civilTimeType:
+		fieldSchema.Type = bigquery.TimeFieldType
+	case civilDateTimeType:
+		fieldSchema.Type = bigquery.DateTimeFieldType
+	case bigRatType:
+		fieldSchema.Type = bigquery.NumericFieldType
+	}
+
+	if fieldSchema.Type != "" {
+		return &fieldSchema, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		fieldSchema.Type = bigquery.StringFieldType
+	case reflect.Bool:
+		fieldSchema.Type = bigquery.BooleanFieldType
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		fieldSchema.Type = bigquery.IntegerFieldType
+	case reflect.Float32, reflect.Float64:
+		fieldSchema.Type = bigquery.FloatFieldType
+	case reflect.Struct:
+		schema, err := bigQuerySchema(t, tag, visited)
+		if err != nil {
+			return nil, err
+		}
+		fieldSchema.Type = bigquery.RecordFieldType
+		fieldSchema.Schema = schema
+	default:
+		return nil, fmt.Errorf("type %s is not supported", t)
+	}
+
+	return &fieldSchema, nil
+}
+
+// BigQueryValueSaver implements bigquery.ValueSaver for Model, a (pointer to a) struct,
+// naming the values like StructToBigQuerySchema
+type BigQueryValueSaver struct {
+	Model    interface{}
+	InsertID string
+	Options  *BigQueryOptions
+}
+
+// NewBigQueryValueSavers returns a BigQueryValueSaver for each element of models, a (pointer to a) slice of structs
+func NewBigQueryValueSavers(models interface{}, options *BigQueryOptions) ([]*BigQueryValueSaver, *errortools.Error) {
+	v := reflect.ValueOf(models)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Slice {
+		return nil, errortools.ErrorMessage("The interface is not a (pointer to a) slice.")
+	}
+
+	savers := []*BigQueryValueSaver{}
+	for i := 0; i < v.Len(); i++ {
+		savers = append(savers, &BigQueryValueSaver{
+			Model:   v.Index(i).Interface(),
+			Options: options,
+		})
+	}
+
+	return savers, nil
+}
+
+// Save implements bigquery.ValueSaver
+func (saver *BigQueryValueSaver) Save() (map[string]bigquery.Value, string, error) {
+	v := reflect.ValueOf(saver.Model)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, "", fmt.Errorf("model of type %T is not a (pointer to a) struct", saver.Model)
+	}
+
+	row := bigQueryRow(v, saver.Options.tag())
+
+	insertID := saver.InsertID
+	if insertID == "" {
+		insertID = bigquery.NoDedupeID
+	}
+
+	return row, insertID, nil
+}
+
+func bigQueryRow(v reflect.Value, tag string) map[string]bigquery.Value {
+	row := make(map[string]bigquery.Value)
+
+	for _, field := range structFields(v.Type(), tag, true, promoteEmbedded) {
+		row[field.name] = bigQueryValue(fieldByIndex(v, field.index), tag)
+	}
+
+	return row
+}
+
+func bigQueryValue(v reflect.Value, tag string) bigquery.Value {
+	if !v.IsValid() {
+		// promoted from nil embedded pointer
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v, _ = bigQueryNullValue(v); !v.IsValid() {
+		return nil
+	}
+
+	switch value := v.Interface().(type) {
+	case []byte:
+		return value
+	case time.Time:
+		return value
+	case civil.Date:
+		return value.String()
+	case civil.Time:
+		return bigquery.CivilTimeString(value)
+	case civil.DateTime:
+		return bigquery.CivilDateTimeString(value)
+	case big.Rat:
+		return bigquery.NumericString(&value)
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		values := []bigquery.Value{}
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, bigQueryValue(v.Index(i), tag))
+		}
+		return values
+	case reflect.Struct:
+		return bigQueryRow(v, tag)
+	}
+
+	return v.Interface()
+}
@@ -0,0 +1,128 @@
+package utilities
+
+import (
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type bigQueryBase struct {
+	Id int64 `bigquery:"id"`
+}
+
+type bigQueryAddress struct {
+	City string `bigquery:"city"`
+}
+
+type bigQueryRowModel struct {
+	bigQueryBase
+	Name    string             `bigquery:"name"`
+	Created time.Time          `bigquery:"created"`
+	Day     civil.Date         `bigquery:"day"`
+	Score   bigquery.NullInt64 `bigquery:"score"`
+	Tags    []string           `bigquery:"tags"`
+	Address bigQueryAddress    `bigquery:"address"`
+	Skipped string             `bigquery:"-"`
+}
+
+func TestStructToBigQuerySchemaMatchesInferSchema(t *testing.T) {
+	schema, e := StructToBigQuerySchema(bigQueryRowModel{}, nil)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	inferred, err := bigquery.InferSchema(bigQueryRowModel{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(schemaFields(schema), schemaFields(inferred)) {
+		t.Fatalf("got %v, inferred %v", schemaFields(schema), schemaFields(inferred))
+	}
+}
+
+// schemaFields describes schema by name, type and mode
+func schemaFields(schema bigquery.Schema) []string {
+	fields := []string{}
+	for _, field := range schema {
+		mode := "NULLABLE"
+		if field.Repeated {
+			mode = "REPEATED"
+		} else if field.Required {
+			mode = "REQUIRED"
+		}
+		fields = append(fields, field.Name+" "+string(field.Type)+" "+mode)
+		for _, nested := range schemaFields(field.Schema) {
+			fields = append(fields, field.Name+"."+nested)
+		}
+	}
+
+	return fields
+}
+
+func TestStructToBigQuerySchemaEmbeddedPointer(t *testing.T) {
+	type model struct {
+		*bigQueryBase
+		Name string `bigquery:"name"`
+		Note string `bigquery:"note,nullable"`
+	}
+
+	schema, e := StructToBigQuerySchema(&model{}, nil)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	// fields promoted from a pointer have no value if it is nil
+	expected := []string{"id INTEGER NULLABLE", "name STRING REQUIRED", "note STRING NULLABLE"}
+	if !reflect.DeepEqual(schemaFields(schema), expected) {
+		t.Fatalf("got %v, expected %v", schemaFields(schema), expected)
+	}
+
+	row, _, err := (&BigQueryValueSaver{Model: model{Name: "a"}}).Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(row, map[string]bigquery.Value{"id": nil, "name": "a", "note": ""}) {
+		t.Fatalf("got %v", row)
+	}
+}
+
+func TestBigQueryValueSaver(t *testing.T) {
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	models := []bigQueryRowModel{{
+		bigQueryBase: bigQueryBase{Id: 7},
+		Name:         "alice",
+		Created:      created,
+		Day:          civil.Date{Year: 2024, Month: 1, Day: 2},
+		Tags:         []string{"a"},
+		Address:      bigQueryAddress{City: "Utrecht"},
+	}}
+
+	savers, e := NewBigQueryValueSavers(&models, nil)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	row, insertID, err := savers[0].Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if insertID != bigquery.NoDedupeID {
+		t.Fatalf("got insert ID '%s'", insertID)
+	}
+
+	expected := map[string]bigquery.Value{
+		"id":      int64(7),
+		"name":    "alice",
+		"created": created,
+		"day":     "2024-01-02",
+		"score":   nil,
+		"tags":    []bigquery.Value{"a"},
+		"address": map[string]bigquery.Value{"city": "Utrecht"},
+	}
+	if !reflect.DeepEqual(row, expected) {
+		t.Fatalf("got %v, expected %v", row, expected)
+	}
+}
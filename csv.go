@@ -0,0 +1,219 @@
+package utilities
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CsvOptions holds the options used when mapping csv records to and from structs
+//
+// In Strict mode mapping aborts at the first value that cannot be assigned to its field,
+// otherwise all invalid values are collected as FieldErrors.
+// NoHeader omits the header row when encoding and Formatters, keyed by column header,
+// override the formatting of values when encoding.
+// Flatten maps the fields of embedded and nested structs to columns as well.
+// SkipRows and SkipTrailingRows are the number of rows ignored at the start, e.g. a preamble
+// preceding the header row, and at the end of a file when decoding.
+// Encoding is the character encoding of the stream read by CsvDecoder, see NewUtf8Reader.
+// NoHeader also applies to decoding, fields then being mapped by position as CsvEncoder writes them:
+// fields tagged with the index option at that index, the other tagged fields in order.
+// NormalizeHeaders matches headers to fields ignoring case, whitespace and accents
+// if no header matches exactly.
+//
+// Besides the column name, the `csv` tag may hold aliases, e.g. `csv:"ArticleNo|Artikelnummer"`,
+// the first of which is used as header when encoding. Decoding fails if no header matches
+// a field tagged with the required option. A map[string]string field tagged with the
+// unmapped option, e.g. `csv:",unmapped"`, receives the columns not mapped to any field,
+// keyed by header or, without header row, by position.
+// The index option, e.g. `csv:",index=3"`, maps a field to the column at that 0-based position
+// regardless of its header.
+type CsvOptions struct {
+	Comma            *rune
+	FieldLayouts     *FieldLayouts
+	Strict           bool
+	NoHeader         bool
+	Formatters       map[string]CsvFormatter
+	Flatten          *FlattenOptions
+	NormalizeHeaders bool
+	SkipRows         int
+	SkipTrailingRows int
+	Encoding         TextEncoding
+}
+
+func (options *CsvOptions) fieldLayouts() *FieldLayouts {
+	if options == nil {
+		return nil
+	}
+
+	return options.FieldLayouts
+}
+
+func (options *CsvOptions) flatten() *FlattenOptions {
+	if options == nil {
+		return nil
+	}
+
+	return options.Flatten
+}
+
+func (options *CsvOptions) strict() bool {
+	if options == nil {
+		return false
+	}
+
+	return options.Strict
+}
+
+func (options *CsvOptions) normalizeHeaders() bool {
+	if options == nil {
+		return false
+	}
+
+	return options.NormalizeHeaders
+}
+
+func (options *CsvOptions) encoding() TextEncoding {
+	if options == nil {
+		return TextEncodingAuto
+	}
+
+	return options.Encoding
+}
+
+func (options *CsvOptions) noHeader() bool {
+	if options == nil {
+		return false
+	}
+
+	return options.NoHeader
+}
+
+func (options *CsvOptions) skipRows() (int, int) {
+	if options == nil {
+		return 0, 0
+	}
+
+	return options.SkipRows, options.SkipTrailingRows
+}
+
+// csvField is a struct field mapped to a csv column
+type csvField struct {
+	structField
+	names    []string // column name followed by its aliases
+	position int      // column index if tagged with the index option, otherwise -1
+}
+
+// header returns the column name written when encoding
+func (field *csvField) header() string {
+	return field.names[0]
+}
+
+var stringMapType = reflect.TypeOf(map[string]string{})
+
+// csvFields returns the fields of structType mapped to csv columns, named after their `csv` tag.
+// Fields without tag are skipped unless includeUntagged is set, as StructToStringArray does, in which case
+// they are named after the field. Fields tagged `csv:"-"` are always skipped.
+// The field tagged with the unmapped option, if any, is returned separately.
+func csvFields(structType reflect.Type, includeUntagged bool, flatten *FlattenOptions) ([]csvField, *structField, error) {
+	var fields []csvField
+	var unmapped *structField
+	var err error
+	positions := make(map[int]string)
+
+	for _, field := range structFields(structType, "csv", includeUntagged, flatten) {
+		if field.options.Contains("unmapped") {
+			if field.field.Type != stringMapType {
+				return nil, nil, fmt.Errorf("field %s tagged unmapped is not a map[string]string", field.fieldName)
+			}
+			if unmapped != nil {
+				return nil, nil, fmt.Errorf("fields %s and %s are both tagged unmapped", unmapped.fieldName, field.fieldName)
+			}
+
+			_field := field
+			unmapped = &_field
+			continue
+		}
+
+		names := []string{field.name}
+
+		tagName, _ := parseTag(field.field.Tag.Get("csv"))
+		if strings.Contains(tagName, "|") {
+			// only the last part of a flattened name holds aliases
+			prefix := strings.TrimSuffix(field.name, tagName)
+
+			names = []string{}
+			for _, alias := range strings.Split(tagName, "|") {
+				names = append(names, prefix+alias)
+			}
+		}
+
+		position := -1
+		if index, ok := field.options.Value("index"); ok {
+			position, err = strconv.Atoi(index)
+			if err != nil || position < 0 {
+				return nil, nil, fmt.Errorf("field %s has invalid index '%s'", field.fieldName, index)
+			}
+			if fieldName, ok := positions[position]; ok {
+				return nil, nil, fmt.Errorf("fields %s and %s have the same index %v", fieldName, field.fieldName, position)
+			}
+			positions[position] = field.fieldName
+		}
+
+		fields = append(fields, csvField{
+			structField: field,
+			names:       names,
+			position:    position,
+		})
+	}
+
+	return fields, unmapped, nil
+}
+
+// csvPositions returns the column index each field is written to: fields tagged with
+// the index option at that index, the other fields in the remaining columns in order
+func csvPositions(fields []csvField) ([]int, int) {
+	taken := make(map[int]bool)
+	width := len(fields)
+	for _, field := range fields {
+		if field.position >= 0 {
+			taken[field.position] = true
+			if field.position >= width {
+				width = field.position + 1
+			}
+		}
+	}
+
+	positions := []int{}
+	next := 0
+	for _, field := range fields {
+		if field.position >= 0 {
+			positions = append(positions, field.position)
+			continue
+		}
+
+		for taken[next] {
+			next++
+		}
+		positions = append(positions, next)
+		next++
+	}
+
+	return positions, width
+}
+
+// normalizeCsvHeader removes accents and whitespace from header and converts it to lower case
+func normalizeCsvHeader(header string) string {
+	return strings.ToLower(strings.Join(strings.Fields(NormalizeString(header, false, nil)), ""))
+}
+
+// cleanCsvCell trims the value
+func cleanCsvCell(value string) string {
+	return strings.Trim(value, " ")
+}
+
+// trimBom removes the UTF-8 byte order mark from the first cell of a file
+func trimBom(value string) string {
+	return strings.TrimPrefix(value, "\uFEFF")
+}
@@ -0,0 +1,61 @@
+package utilities
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type aliasRow struct {
+	Article string            `csv:"ArticleNo|Artikelnummer,required"`
+	Name    string            `csv:"Omschrijving"`
+	Other   map[string]string `csv:",unmapped"`
+}
+
+func TestCsvHeaderAliases(t *testing.T) {
+	records := [][]string{
+		{"Artikelnummer", "Omschrijving", "Prijs"},
+		{"A1", "Hamer", "9,95"},
+	}
+
+	var rows []aliasRow
+	if e := StringArrayToStruct(&records, &rows); e != nil {
+		t.Fatal(e.Message())
+	}
+
+	expected := []aliasRow{{Article: "A1", Name: "Hamer", Other: map[string]string{"Prijs": "9,95"}}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("got %+v, expected %+v", rows, expected)
+	}
+}
+
+func TestCsvNormalizeHeaders(t *testing.T) {
+	data := "artikel nummer,OMSCHRIJVING\nA1,Hamer\n"
+
+	rows := decodeCsvRows[aliasRow](t, data, &CsvOptions{NormalizeHeaders: true})
+
+	expected := []aliasRow{{Article: "A1", Name: "Hamer", Other: map[string]string{}}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("got %+v, expected %+v", rows, expected)
+	}
+
+	decoder := NewCsvDecoder(strings.NewReader(data), nil)
+	if e := decoder.ReadHeader(&aliasRow{}); e == nil {
+		t.Fatal("matched headers without NormalizeHeaders")
+	}
+}
+
+func TestCsvRequiredColumns(t *testing.T) {
+	records := [][]string{{"Omschrijving"}, {"Hamer"}}
+
+	var rows []aliasRow
+	if e := StringArrayToStruct(&records, &rows); e == nil || !strings.Contains(e.Message(), "ArticleNo") {
+		t.Fatalf("got %v", e)
+	}
+
+	// the header is checked before any record is read
+	decoder := NewCsvDecoder(strings.NewReader("Omschrijving\n"), nil)
+	if e := decoder.ReadHeader(&aliasRow{}); e == nil || e.Message() != "Missing required column(s): ArticleNo." {
+		t.Fatalf("got %v", e)
+	}
+}
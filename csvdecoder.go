@@ -0,0 +1,425 @@
+package utilities
+
+import (
+	"encoding/csv"
+	"errors"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type csvColumn struct {
+	recordIndex int
+	fieldIndex  []int
+	fieldName   string
+	header      string
+}
+
+// csvMapping maps the columns of a csv file to the fields of a struct type
+type csvMapping struct {
+	structType reflect.Type
+	columns    []csvColumn
+	unmapped   *structField
+	header     []string     // nil for files without header row
+	isMapped   map[int]bool // columns excluded from the unmapped field
+}
+
+// newCsvMapping maps the columns of header to the fields of structType, or by position if NoHeader is set
+func newCsvMapping(structType reflect.Type, header []string, options *CsvOptions) (*csvMapping, *errortools.Error) {
+	fields, unmapped, err := csvFields(structType, false, options.flatten())
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	// without header row fields are mapped by position, as written by CsvEncoder
+	noHeader := options.noHeader()
+
+	var positions []int
+	var width int
+	if noHeader {
+		positions, width = csvPositions(fields)
+	}
+
+	mapping := csvMapping{
+		structType: structType,
+		unmapped:   unmapped,
+		isMapped:   make(map[int]bool),
+	}
+
+	// columns CsvEncoder leaves empty between index positions do not count as unmapped
+	for recordIndex := 0; recordIndex < width; recordIndex++ {
+		mapping.isMapped[recordIndex] = true
+	}
+
+	if !noHeader {
+		mapping.header = make([]string, len(header))
+	}
+
+	headerIndex := make(map[string]int)
+	normalizedHeaderIndex := make(map[string]int)
+	for cellIndex, cellValue := range header {
+		cellValue = cleanCsvCell(cellValue)
+		mapping.header[cellIndex] = cellValue
+
+		headerIndex[cellValue] = cellIndex
+		if options.normalizeHeaders() {
+			normalizedHeaderIndex[normalizeCsvHeader(cellValue)] = cellIndex
+		}
+	}
+
+	missing := []string{}
+
+	for i, field := range fields {
+		recordIndex, ok := field.recordIndex(headerIndex, normalizedHeaderIndex)
+		if noHeader {
+			recordIndex, ok = positions[i], true
+		}
+		if !ok {
+			if field.options.Contains("required") {
+				missing = append(missing, field.header())
+			}
+			continue
+		}
+
+		columnHeader := field.header()
+		if recordIndex < len(mapping.header) && mapping.header[recordIndex] != "" {
+			columnHeader = mapping.header[recordIndex]
+		}
+
+		mapping.isMapped[recordIndex] = true
+		mapping.columns = append(mapping.columns, csvColumn{
+			recordIndex: recordIndex,
+			fieldIndex:  field.index,
+			fieldName:   field.fieldName,
+			header:      columnHeader,
+		})
+	}
+
+	if len(missing) > 0 {
+		return nil, errortools.ErrorMessagef("Missing required column(s): %s.", strings.Join(missing, ", "))
+	}
+
+	return &mapping, nil
+}
+
+// recordIndex returns the position of field if tagged with the index option,
+// otherwise the index of the first header matching one of its names
+func (field *csvField) recordIndex(headerIndex map[string]int, normalizedHeaderIndex map[string]int) (int, bool) {
+	if field.position >= 0 {
+		return field.position, true
+	}
+
+	for _, name := range field.names {
+		recordIndex, ok := headerIndex[name]
+		if ok {
+			return recordIndex, true
+		}
+	}
+
+	for _, name := range field.names {
+		recordIndex, ok := normalizedHeaderIndex[normalizeCsvHeader(name)]
+		if ok {
+			return recordIndex, true
+		}
+	}
+
+	return 0, false
+}
+
+// decode assigns the values of record to struct v, row being the 1-based row number used in errors
+func (mapping *csvMapping) decode(record []string, v reflect.Value, row int, options *CsvOptions) FieldErrors {
+	var fieldErrors FieldErrors
+
+	for _, column := range mapping.columns {
+		if column.recordIndex >= len(record) {
+			continue
+		}
+
+		value := cleanCsvCell(record[column.recordIndex])
+
+		var f reflect.Value
+		if value == "" {
+			// leave nil pointers to nested structs untouched
+			f = fieldByIndex(v, column.fieldIndex)
+			if !f.IsValid() {
+				continue
+			}
+		} else {
+			f = fieldByIndexAlloc(v, column.fieldIndex)
+		}
+
+		var err error
+		if f.IsValid() {
+			err = setStructFieldFromString(f, value, options.fieldLayouts())
+		} else {
+			err = errUnexportedEmbeddedPointer
+		}
+		if err != nil {
+			fieldErrors = append(fieldErrors, &FieldError{
+				Row:    row,
+				Column: column.header,
+				Field:  column.fieldName,
+				Value:  value,
+				Err:    err,
+			})
+
+			if options.strict() {
+				break
+			}
+		}
+	}
+
+	if mapping.unmapped != nil {
+		unmapped := make(map[string]string)
+		for recordIndex, value := range record {
+			if mapping.isMapped[recordIndex] {
+				continue
+			}
+
+			key := strconv.Itoa(recordIndex)
+			if mapping.header != nil {
+				if recordIndex >= len(mapping.header) || mapping.header[recordIndex] == "" {
+					continue
+				}
+				key = mapping.header[recordIndex]
+			}
+
+			unmapped[key] = cleanCsvCell(value)
+		}
+		if f := fieldByIndexAlloc(v, mapping.unmapped.index); f.IsValid() {
+			f.Set(reflect.ValueOf(unmapped))
+		}
+	}
+
+	return fieldErrors
+}
+
+// CsvDecoder reads csv records from an io.Reader and decodes them one at a time
+// into structs, using the same `csv` tag mapping as StringArrayToStruct
+//
+// Usage:
+//
+//	decoder := NewCsvDecoder(reader, nil)
+//	if e := decoder.ReadHeader(&Row{}); e != nil {
+//		return e
+//	}
+//	for decoder.Next() {
+//		var row Row
+//		if e := decoder.Decode(&row); e != nil {
+//			return e
+//		}
+//	}
+//	if e := decoder.Err(); e != nil {
+//		return e
+//	}
+type CsvDecoder struct {
+	reader      *csv.Reader
+	options     *CsvOptions
+	started     bool
+	eof         bool
+	header      []string
+	pending     []csvRecord
+	record      []string
+	row         int // rows read
+	recordRow   int // row number of record
+	mapping     *csvMapping
+	fieldErrors FieldErrors
+	err         *errortools.Error
+}
+
+// csvRecord is a record read ahead in order to skip trailing rows
+type csvRecord struct {
+	values []string
+	row    int
+}
+
+// NewCsvDecoder returns a decoder reading from reader, transcoded to UTF-8 according to the Encoding option
+func NewCsvDecoder(reader io.Reader, options *CsvOptions) *CsvDecoder {
+	csvReader := csv.NewReader(NewUtf8Reader(reader, options.encoding()))
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+
+	if options != nil {
+		if options.Comma != nil {
+			csvReader.Comma = *options.Comma
+		}
+	}
+
+	return &CsvDecoder{
+		reader:  csvReader,
+		options: options,
+	}
+}
+
+// Header returns the header row, read at the first call of Next, nil if NoHeader is set
+func (decoder *CsvDecoder) Header() []string {
+	return decoder.header
+}
+
+// Next advances the decoder to the next record, skipping leading rows and reading the header
+// row first if needed. It returns false when there are no more records, trailing rows to skip
+// excluded, or an error occurred, see Err.
+func (decoder *CsvDecoder) Next() bool {
+	decoder.record = nil
+
+	if decoder.err != nil {
+		return false
+	}
+
+	_, skipTrailingRows := decoder.options.skipRows()
+
+	decoder.start()
+
+	for !decoder.eof && len(decoder.pending) <= skipTrailingRows {
+		record, err := decoder.read()
+		if err != nil {
+			decoder.eof = true
+			break
+		}
+
+		if skipTrailingRows > 0 {
+			// records are reused by the csv reader
+			record = append([]string{}, record...)
+		}
+
+		decoder.pending = append(decoder.pending, csvRecord{
+			values: record,
+			row:    decoder.row,
+		})
+	}
+
+	if decoder.err != nil || len(decoder.pending) <= skipTrailingRows {
+		return false
+	}
+
+	decoder.record = decoder.pending[0].values
+	decoder.recordRow = decoder.pending[0].row
+	decoder.pending = decoder.pending[1:]
+
+	return true
+}
+
+// start skips the leading rows and reads the header row at the first call
+func (decoder *CsvDecoder) start() {
+	if decoder.started {
+		return
+	}
+	decoder.started = true
+
+	skipRows, _ := decoder.options.skipRows()
+
+	for i := 0; i < skipRows; i++ {
+		_, err := decoder.read()
+		if err != nil {
+			decoder.eof = true
+			return
+		}
+	}
+
+	if !decoder.options.noHeader() {
+		header, err := decoder.read()
+		if err != nil {
+			decoder.eof = true
+			return
+		}
+
+		decoder.header = append([]string{}, header...)
+	}
+}
+
+// ReadHeader reads the header row, if not read yet, and maps its columns to the fields of model,
+// a pointer to a struct, returning an error if required columns are missing. Call it before Next
+// to check the header of files without any records as well, Decode checks it otherwise.
+func (decoder *CsvDecoder) ReadHeader(model interface{}) *errortools.Error {
+	if reflect.TypeOf(model).Kind() != reflect.Ptr {
+		return errortools.ErrorMessage("The interface is not a pointer.")
+	}
+
+	structType := reflect.TypeOf(model).Elem()
+	if structType.Kind() != reflect.Struct {
+		return errortools.ErrorMessage("The interface is not a pointer to a struct.")
+	}
+
+	decoder.start()
+	if decoder.err != nil {
+		return decoder.err
+	}
+
+	return decoder.mapTo(structType)
+}
+
+// mapTo sets the mapping of the columns to the fields of structType, unless already set
+func (decoder *CsvDecoder) mapTo(structType reflect.Type) *errortools.Error {
+	if decoder.mapping != nil && decoder.mapping.structType == structType {
+		return nil
+	}
+
+	mapping, e := newCsvMapping(structType, decoder.header, decoder.options)
+	if e != nil {
+		return e
+	}
+	decoder.mapping = mapping
+
+	return nil
+}
+
+func (decoder *CsvDecoder) read() ([]string, error) {
+	record, err := decoder.reader.Read()
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			decoder.err = errortools.ErrorMessage(err)
+		}
+		return nil, err
+	}
+	decoder.row++
+
+	return record, nil
+}
+
+// Decode decodes the current record into model, which must be a pointer to a struct.
+// In strict mode the first invalid value is returned as error, otherwise invalid values
+// are skipped and collected, see FieldErrors.
+func (decoder *CsvDecoder) Decode(model interface{}) *errortools.Error {
+	if decoder.record == nil {
+		return errortools.ErrorMessage("No current record, call Next first.")
+	}
+
+	if reflect.TypeOf(model).Kind() != reflect.Ptr {
+		return errortools.ErrorMessage("The interface is not a pointer.")
+	}
+
+	v := reflect.ValueOf(model).Elem()
+	if v.Kind() != reflect.Struct {
+		return errortools.ErrorMessage("The interface is not a pointer to a struct.")
+	}
+
+	e := decoder.mapTo(v.Type())
+	if e != nil {
+		return e
+	}
+
+	fieldErrors := decoder.mapping.decode(decoder.record, v, decoder.recordRow, decoder.options)
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+
+	decoder.fieldErrors = append(decoder.fieldErrors, fieldErrors...)
+
+	if decoder.options.strict() {
+		return errortools.ErrorMessage(fieldErrors[0])
+	}
+
+	return nil
+}
+
+// FieldErrors returns the invalid values collected by Decode so far
+func (decoder *CsvDecoder) FieldErrors() FieldErrors {
+	return decoder.fieldErrors
+}
+
+// Err returns the first read error encountered by Next, io.EOF excluded
+func (decoder *CsvDecoder) Err() *errortools.Error {
+	return decoder.err
+}
@@ -0,0 +1,155 @@
+package utilities
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type decoderRow struct {
+	Id   int    `csv:"id"`
+	Name string `csv:"name"`
+}
+
+func decodeCsv(t *testing.T, data string, options *CsvOptions) ([]decoderRow, *CsvDecoder) {
+	t.Helper()
+
+	decoder := NewCsvDecoder(strings.NewReader(data), options)
+
+	var rows []decoderRow
+	for decoder.Next() {
+		var row decoderRow
+		if e := decoder.Decode(&row); e != nil {
+			t.Fatal(e.Message())
+		}
+		rows = append(rows, row)
+	}
+	if e := decoder.Err(); e != nil {
+		t.Fatal(e.Message())
+	}
+
+	return rows, decoder
+}
+
+func TestCsvDecoder(t *testing.T) {
+	rows, decoder := decodeCsv(t, "name,id,other\nalice,1,x\nbob,2,y\n", nil)
+
+	expected := []decoderRow{{Id: 1, Name: "alice"}, {Id: 2, Name: "bob"}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("got %+v, expected %+v", rows, expected)
+	}
+	if !reflect.DeepEqual(decoder.Header(), []string{"name", "id", "other"}) {
+		t.Fatalf("got header %v", decoder.Header())
+	}
+}
+
+func TestCsvDecoderMatchesStringArrayToStruct(t *testing.T) {
+	records := [][]string{{"id", "name"}, {"1", "alice"}, {"2", "bob"}}
+
+	var expected []decoderRow
+	if e := StringArrayToStruct(&records, &expected); e != nil {
+		t.Fatal(e.Message())
+	}
+
+	rows, _ := decodeCsv(t, "id,name\n1,alice\n2,bob\n", nil)
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("got %+v, expected %+v", rows, expected)
+	}
+}
+
+func TestCsvDecoderEmpty(t *testing.T) {
+	for _, data := range []string{"", "id,name\n"} {
+		rows, _ := decodeCsv(t, data, nil)
+		if len(rows) != 0 {
+			t.Fatalf("decoded %d rows from '%s'", len(rows), data)
+		}
+	}
+}
+
+func TestCsvDecoderDecodeBeforeNext(t *testing.T) {
+	decoder := NewCsvDecoder(strings.NewReader("id,name\n1,alice\n"), nil)
+
+	var row decoderRow
+	if e := decoder.Decode(&row); e == nil {
+		t.Fatal("decoded without current record")
+	}
+}
+
+func TestCsvDecoderComma(t *testing.T) {
+	comma := ';'
+	rows, _ := decodeCsv(t, "id;name\n1;alice\n", &CsvOptions{Comma: &comma})
+
+	if len(rows) != 1 || rows[0] != (decoderRow{Id: 1, Name: "alice"}) {
+		t.Fatalf("got %+v", rows)
+	}
+}
+
+type positionalRow struct {
+	A string            `csv:"a"`
+	B int               `csv:"b,index=3"`
+	C string            `csv:"c"`
+	X map[string]string `csv:",unmapped"`
+}
+
+func TestCsvHeaderlessRoundTrip(t *testing.T) {
+	options := &CsvOptions{NoHeader: true}
+
+	rows := []positionalRow{{A: "a", B: 5, C: "c"}}
+
+	data := encodeCsv(t, rows, options)
+	if data != "a,c,,5\n" {
+		t.Fatalf("encoded '%s'", data)
+	}
+
+	// the column filling the gap before index 3 is not unmapped
+	expected := []positionalRow{{A: "a", B: 5, C: "c", X: map[string]string{}}}
+
+	decoded := decodeCsvRows[positionalRow](t, data, options)
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Fatalf("decoded %+v, expected %+v", decoded, expected)
+	}
+
+	records := [][]string{{"a", "c", "", "5", "extra"}}
+	var converted []positionalRow
+	if _, e := StringArrayToStructWithOptions(&records, &converted, options); e != nil {
+		t.Fatal(e.Message())
+	}
+	expected[0].X = map[string]string{"4": "extra"}
+	if !reflect.DeepEqual(converted, expected) {
+		t.Fatalf("converted %+v, expected %+v", converted, expected)
+	}
+}
+
+func TestCsvHeaderRoundTripWithIndex(t *testing.T) {
+	rows := []positionalRow{{A: "a", B: 5, C: "c"}}
+
+	data := encodeCsv(t, rows, nil)
+	if data != "a,c,,b\na,c,,5\n" {
+		t.Fatalf("encoded '%s'", data)
+	}
+
+	expected := []positionalRow{{A: "a", B: 5, C: "c", X: map[string]string{}}}
+	if decoded := decodeCsvRows[positionalRow](t, data, nil); !reflect.DeepEqual(decoded, expected) {
+		t.Fatalf("decoded %+v, expected %+v", decoded, expected)
+	}
+}
+
+func TestCsvSkipRows(t *testing.T) {
+	data := "Export of articles\nDate: 2024-01-02\nid,name\n1,alice\n2,bob\nTotal: 2\n"
+	options := &CsvOptions{SkipRows: 2, SkipTrailingRows: 1}
+
+	rows, _ := decodeCsv(t, data, options)
+	expected := []decoderRow{{Id: 1, Name: "alice"}, {Id: 2, Name: "bob"}}
+	if !reflect.DeepEqual(rows, expected) {
+		t.Fatalf("got %+v, expected %+v", rows, expected)
+	}
+
+	records := [][]string{{"Export of articles"}, {"Date: 2024-01-02"}, {"id", "name"}, {"1", "alice"}, {"2", "bob"}, {"Total: 2"}}
+	var converted []decoderRow
+	if _, e := StringArrayToStructWithOptions(&records, &converted, options); e != nil {
+		t.Fatal(e.Message())
+	}
+	if !reflect.DeepEqual(converted, expected) {
+		t.Fatalf("converted %+v, expected %+v", converted, expected)
+	}
+}
@@ -0,0 +1,151 @@
+package utilities
+
+import (
+	"encoding/csv"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"io"
+	"reflect"
+	"time"
+)
+
+// csvTimestampLayout is the default layout of time.Time values written by CsvEncoder,
+// preserving sub-seconds and time zone so CsvDecoder reads back the same instant
+const csvTimestampLayout string = time.RFC3339Nano
+
+// CsvFormatter formats a field value as csv cell, overriding the default formatting
+type CsvFormatter func(value interface{}) (string, error)
+
+// CsvEncoder writes structs as csv records to an io.Writer, the header row
+// being written before the first record unless NoHeader is set.
+// Only fields with a `csv` tag are written, as CsvDecoder only maps those.
+// Unless TimestampLayout is set, time.Time values are written in RFC 3339 with nanoseconds.
+type CsvEncoder struct {
+	writer     *csv.Writer
+	options    *CsvOptions
+	structType reflect.Type
+	columns    []csvField
+	positions  []int
+	width      int
+}
+
+func NewCsvEncoder(writer io.Writer, options *CsvOptions) *CsvEncoder {
+	csvWriter := csv.NewWriter(writer)
+
+	if options != nil {
+		if options.Comma != nil {
+			csvWriter.Comma = *options.Comma
+		}
+	}
+
+	return &CsvEncoder{
+		writer:  csvWriter,
+		options: options,
+	}
+}
+
+// Encode writes model, a struct or pointer to a struct, as csv record
+func (encoder *CsvEncoder) Encode(model interface{}) *errortools.Error {
+	v := reflect.ValueOf(model)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return errortools.ErrorMessage("The interface is not a (pointer to a) struct.")
+	}
+
+	if encoder.structType == nil {
+		columns, _, err := csvFields(v.Type(), false, encoder.options.flatten())
+		if err != nil {
+			return errortools.ErrorMessage(err)
+		}
+
+		encoder.structType = v.Type()
+		encoder.columns = columns
+		encoder.positions, encoder.width = csvPositions(columns)
+
+		if encoder.options == nil || !encoder.options.NoHeader {
+			header := make([]string, encoder.width)
+			for i, column := range encoder.columns {
+				header[encoder.positions[i]] = column.header()
+			}
+
+			err := encoder.writer.Write(header)
+			if err != nil {
+				return errortools.ErrorMessage(err)
+			}
+		}
+	} else if encoder.structType != v.Type() {
+		return errortools.ErrorMessagef("Cannot encode %s, encoder is set up for %s.", v.Type(), encoder.structType)
+	}
+
+	record := make([]string, encoder.width)
+	for i, column := range encoder.columns {
+		cell, err := encoder.format(column, fieldByIndex(v, column.index))
+		if err != nil {
+			return errortools.ErrorMessagef("Column '%s': %s", column.header(), err.Error())
+		}
+
+		record[encoder.positions[i]] = cell
+	}
+
+	err := encoder.writer.Write(record)
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	return nil
+}
+
+func (encoder *CsvEncoder) format(column csvField, f reflect.Value) (string, error) {
+	if !f.IsValid() {
+		// nested in nil pointer
+		return "", nil
+	}
+
+	if column.options.Contains("omitempty") && f.IsZero() {
+		return "", nil
+	}
+
+	if encoder.options != nil {
+		formatter, ok := encoder.options.Formatters[column.header()]
+		if ok {
+			return formatter(f.Interface())
+		}
+	}
+
+	return formatValueWithTimestampLayout(f, encoder.options.fieldLayouts(), csvTimestampLayout)
+}
+
+// EncodeAll writes all elements of model, a slice or pointer to a slice of structs, and flushes the writer
+func (encoder *CsvEncoder) EncodeAll(model interface{}) *errortools.Error {
+	v := reflect.ValueOf(model)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Slice {
+		return errortools.ErrorMessage("The interface is not a (pointer to a) slice.")
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		e := encoder.Encode(v.Index(i).Interface())
+		if e != nil {
+			return e
+		}
+	}
+
+	return encoder.Flush()
+}
+
+// Flush writes any buffered data to the underlying io.Writer
+func (encoder *CsvEncoder) Flush() *errortools.Error {
+	encoder.writer.Flush()
+
+	err := encoder.writer.Error()
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package utilities
+
+import (
+	"bytes"
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type encoderRow struct {
+	Id       int                `csv:"id"`
+	Name     string             `csv:"name"`
+	Price    float64            `csv:"price"`
+	Active   bool               `csv:"active"`
+	Count    *int               `csv:"count"`
+	When     time.Time          `csv:"when"`
+	Date     civil.Date         `csv:"date"`
+	Score    bigquery.NullInt64 `csv:"score"`
+	Note     string             `csv:"note,omitempty"`
+	Secret   string             `csv:"-"`
+	Untagged string
+}
+
+func encodeCsv(t *testing.T, rows interface{}, options *CsvOptions) string {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	if e := NewCsvEncoder(&buffer, options).EncodeAll(rows); e != nil {
+		t.Fatal(e.Message())
+	}
+
+	return buffer.String()
+}
+
+func decodeCsvRows[T any](t *testing.T, data string, options *CsvOptions) []T {
+	t.Helper()
+
+	decoder := NewCsvDecoder(bytes.NewBufferString(data), options)
+
+	var rows []T
+	for decoder.Next() {
+		var row T
+		if e := decoder.Decode(&row); e != nil {
+			t.Fatal(e.Message())
+		}
+		rows = append(rows, row)
+	}
+	if e := decoder.Err(); e != nil {
+		t.Fatal(e.Message())
+	}
+	if fieldErrors := decoder.FieldErrors(); len(fieldErrors) > 0 {
+		t.Fatal(fieldErrors)
+	}
+
+	return rows
+}
+
+func TestCsvEncoderRoundTrip(t *testing.T) {
+	count := 0
+	location := time.FixedZone("CET", 3600)
+
+	rows := []encoderRow{
+		{
+			Id:     1,
+			Name:   "alice, \"the\" first",
+			Price:  1234.5678,
+			Active: true,
+			Count:  &count,
+			When:   time.Date(2024, 1, 2, 3, 4, 5, 123456789, location),
+			Date:   civil.Date{Year: 2024, Month: 2, Day: 29},
+			Score:  bigquery.NullInt64{Int64: 0, Valid: true},
+			Note:   "note",
+		},
+		{
+			Id: 2,
+		},
+	}
+
+	decimalSeparator, thousandsSeparator, trueValue, falseValue := ",", ".", "ja", "nee"
+
+	for _, options := range []*CsvOptions{
+		nil,
+		{FieldLayouts: &FieldLayouts{DecimalSeparator: &decimalSeparator, ThousandsSeparator: &thousandsSeparator, TrueValue: &trueValue, FalseValue: &falseValue}},
+	} {
+		decoded := decodeCsvRows[encoderRow](t, encodeCsv(t, rows, options), options)
+
+		if len(decoded) != len(rows) {
+			t.Fatalf("decoded %d rows, expected %d", len(decoded), len(rows))
+		}
+		for i := range rows {
+			if !decoded[i].When.Equal(rows[i].When) {
+				t.Fatalf("decoded time %v, expected %v", decoded[i].When, rows[i].When)
+			}
+			decoded[i].When = rows[i].When
+
+			if !reflect.DeepEqual(decoded[i], rows[i]) {
+				t.Fatalf("decoded %+v, expected %+v", decoded[i], rows[i])
+			}
+		}
+	}
+}
+
+func TestCsvEncoderTagOptions(t *testing.T) {
+	rows := []encoderRow{{Id: 1, Secret: "secret", Untagged: "untagged"}}
+
+	data := encodeCsv(t, rows, nil)
+
+	expected := "id,name,price,active,count,when,date,score,note\n1,,0,FALSE,,0001-01-01T00:00:00Z,,,\n"
+	if data != expected {
+		t.Fatalf("encoded\n%s\nexpected\n%s", data, expected)
+	}
+}
+
+func TestCsvEncoderFormatters(t *testing.T) {
+	options := &CsvOptions{
+		NoHeader: true,
+		Formatters: map[string]CsvFormatter{
+			"price": func(value interface{}) (string, error) {
+				return fmt.Sprintf("EUR %.2f", value), nil
+			},
+		},
+	}
+
+	data := encodeCsv(t, []decoderRow{{Id: 1, Name: "alice"}}, options)
+	if data != "1,alice\n" {
+		t.Fatalf("encoded '%s'", data)
+	}
+
+	data = encodeCsv(t, []fieldErrorRow{{Id: 1, Price: 9.5}}, options)
+	if data != "1,EUR 9.50,\n" {
+		t.Fatalf("encoded '%s'", data)
+	}
+}
+
+func TestCsvEncoderOtherType(t *testing.T) {
+	var buffer bytes.Buffer
+	encoder := NewCsvEncoder(&buffer, nil)
+
+	if e := encoder.Encode(decoderRow{}); e != nil {
+		t.Fatal(e.Message())
+	}
+	if e := encoder.Encode(fieldErrorRow{}); e == nil {
+		t.Fatal("encoded other struct type")
+	}
+}
@@ -0,0 +1,41 @@
+package utilities
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes a value that could not be assigned to a struct field,
+// Row being 0 if the value does not originate from a file
+type FieldError struct {
+	Row    int
+	Column string
+	Field  string
+	Value  string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	message := fmt.Sprintf("column '%s' (field %s): invalid value '%s': %s", e.Column, e.Field, e.Value, e.Err.Error())
+	if e.Row > 0 {
+		message = fmt.Sprintf("row %v, %s", e.Row, message)
+	}
+
+	return message
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// FieldErrors is a collection of FieldError
+type FieldErrors []*FieldError
+
+func (errs FieldErrors) Error() string {
+	messages := []string{}
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
@@ -0,0 +1,88 @@
+package utilities
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type fieldErrorRow struct {
+	Id    int     `csv:"id"`
+	Price float64 `csv:"price"`
+	Name  string  `csv:"name"`
+}
+
+func TestStringArrayToStructFieldErrors(t *testing.T) {
+	records := [][]string{
+		{"id", "price", "name"},
+		{"1", "9.95", "alice"},
+		{"x", "y", "bob"},
+		{"3", "1.5", "carol"},
+	}
+
+	var rows []fieldErrorRow
+	fieldErrors, e := StringArrayToStructWithOptions(&records, &rows, nil)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	// lenient mode skips invalid values but keeps the rows
+	if len(rows) != 3 || rows[1] != (fieldErrorRow{Name: "bob"}) {
+		t.Fatalf("got rows %+v", rows)
+	}
+
+	if len(fieldErrors) != 2 {
+		t.Fatalf("got field errors %v, expected 2", fieldErrors)
+	}
+
+	fieldError := fieldErrors[0]
+	if fieldError.Row != 3 || fieldError.Column != "id" || fieldError.Field != "Id" || fieldError.Value != "x" {
+		t.Fatalf("got field error %+v", fieldError)
+	}
+	if !errors.Is(fieldError, strconv.ErrSyntax) {
+		t.Fatalf("field error %v does not wrap strconv.ErrSyntax", fieldError)
+	}
+	if !strings.HasPrefix(fieldError.Error(), "row 3, column 'id' (field Id): invalid value 'x'") {
+		t.Fatalf("got message '%s'", fieldError.Error())
+	}
+}
+
+func TestStringArrayToStructStrict(t *testing.T) {
+	records := [][]string{
+		{"id", "price", "name"},
+		{"1", "9.95", "alice"},
+		{"x", "y", "bob"},
+		{"3", "1.5", "carol"},
+	}
+
+	var rows []fieldErrorRow
+	fieldErrors, e := StringArrayToStructWithOptions(&records, &rows, &CsvOptions{Strict: true})
+	if e == nil {
+		t.Fatal("no error in strict mode")
+	}
+
+	// strict mode stops at the first invalid value
+	if len(fieldErrors) != 1 || fieldErrors[0].Column != "id" {
+		t.Fatalf("got field errors %v", fieldErrors)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, expected 1", len(rows))
+	}
+}
+
+func TestCsvDecoderFieldErrors(t *testing.T) {
+	decoder := NewCsvDecoder(strings.NewReader("id,price\n1,x\n2,3\n"), nil)
+
+	for decoder.Next() {
+		var row fieldErrorRow
+		if e := decoder.Decode(&row); e != nil {
+			t.Fatal(e.Message())
+		}
+	}
+
+	fieldErrors := decoder.FieldErrors()
+	if len(fieldErrors) != 1 || fieldErrors[0].Row != 2 || fieldErrors[0].Column != "price" {
+		t.Fatalf("got field errors %v", fieldErrors)
+	}
+}
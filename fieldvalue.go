@@ -0,0 +1,653 @@
+package utilities
+
+import (
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	civilDateType       = reflect.TypeOf(civil.Date{})
+	civilTimeType       = reflect.TypeOf(civil.Time{})
+	civilDateTimeType   = reflect.TypeOf(civil.DateTime{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// setStructFieldFromString parses value and assigns it to field f,
+// an empty value sets a pointer field to nil and leaves other fields untouched
+func setStructFieldFromString(f reflect.Value, value string, fieldLayouts *FieldLayouts) error {
+	if value == "" {
+		if f.Kind() == reflect.Ptr {
+			f.Set(reflect.Zero(f.Type()))
+		}
+		return nil
+	}
+
+	v, err := parseString(value, f.Type(), fieldLayouts)
+	if err != nil {
+		return err
+	}
+
+	f.Set(v)
+
+	return nil
+}
+
+// parseString parses value into a new value of type t
+func parseString(value string, t reflect.Type, fieldLayouts *FieldLayouts) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+
+	if parser := registry.parser(t); parser != nil {
+		parsed, err := parser(value, t, fieldLayouts)
+		if err != nil {
+			return v, err
+		}
+		p := reflect.ValueOf(parsed)
+		if !p.IsValid() || !p.Type().AssignableTo(t) {
+			return v, fmt.Errorf("parser registered for %s returned %T", t, parsed)
+		}
+		v.Set(p)
+		return v, nil
+	}
+
+	switch t {
+	case timeType:
+		_t, err := parseTimeInLocation(value, timestampParseLayouts(fieldLayouts), fieldLayouts.location())
+		if err != nil {
+			return v, err
+		}
+		v.Set(reflect.ValueOf(_t))
+		return v, nil
+	case civilDateType:
+		_t, err := parseTime(value, dateParseLayouts(fieldLayouts))
+		if err != nil {
+			return v, err
+		}
+		v.Set(reflect.ValueOf(civil.DateOf(_t)))
+		return v, nil
+	case civilTimeType:
+		_t, err := parseTime(value, timeParseLayouts(fieldLayouts))
+		if err != nil {
+			return v, err
+		}
+		v.Set(reflect.ValueOf(civil.TimeOf(_t)))
+		return v, nil
+	case civilDateTimeType:
+		_t, err := parseTime(value, dateTimeParseLayouts(fieldLayouts))
+		if err != nil {
+			return v, err
+		}
+		v.Set(reflect.ValueOf(civil.DateTimeOf(_t)))
+		return v, nil
+	}
+
+	if isBigQueryNullType(t) {
+		inner, err := parseString(value, t.Field(0).Type, fieldLayouts)
+		if err != nil {
+			return v, err
+		}
+		return newBigQueryNull(t, inner), nil
+	}
+
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+		return v, err
+	}
+
+	if t.Kind() == reflect.Ptr {
+		inner, err := parseString(value, t.Elem(), fieldLayouts)
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(inner)
+		return p, nil
+	}
+
+	if isBasicKind(t.Kind()) {
+		return parseBasic(value, t, fieldLayouts)
+	}
+
+	// unmarshal to this type with input the json representation of the string value
+	b, err := json.Marshal(value)
+	if err != nil {
+		return v, err
+	}
+	err = json.Unmarshal(b, v.Addr().Interface())
+
+	return v, err
+}
+
+// isBasicKind reports whether values of kind k are strings, bools or numbers,
+// which formatBasic and parseBasic handle
+func isBasicKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+
+	return false
+}
+
+// parseBasic parses value into a new value of type t, which is of a basic kind
+func parseBasic(value string, t reflect.Type, fieldLayouts *FieldLayouts) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := fieldLayouts.parseBool(value)
+		if err != nil {
+			return v, err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(fieldLayouts.normalizeNumber(value), 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(fieldLayouts.normalizeNumber(value), 10, t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(fieldLayouts.normalizeNumber(value), t.Bits())
+		if err != nil {
+			return v, err
+		}
+		v.SetFloat(f)
+	}
+
+	return v, nil
+}
+
+// formatBasic formats v, which is of a basic kind
+func formatBasic(v reflect.Value, fieldLayouts *FieldLayouts) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return fieldLayouts.formatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fieldLayouts.formatNumber(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return fieldLayouts.formatNumber(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		return fieldLayouts.formatNumber(strconv.FormatFloat(v.Float(), 'f', fieldLayouts.precision(), v.Type().Bits()))
+	}
+
+	return ""
+}
+
+func isBigQueryNullType(t reflect.Type) bool {
+	switch t {
+	case reflect.TypeOf(bigquery.NullString{}),
+		reflect.TypeOf(bigquery.NullInt64{}),
+		reflect.TypeOf(bigquery.NullFloat64{}),
+		reflect.TypeOf(bigquery.NullBool{}),
+		reflect.TypeOf(bigquery.NullTimestamp{}),
+		reflect.TypeOf(bigquery.NullDate{}),
+		reflect.TypeOf(bigquery.NullTime{}),
+		reflect.TypeOf(bigquery.NullDateTime{}),
+		reflect.TypeOf(bigquery.NullGeography{}),
+		reflect.TypeOf(bigquery.NullJSON{}):
+		return true
+	}
+
+	return false
+}
+
+// bigQueryNullValue returns the value held by v if v is a bigquery.Null* value, an invalid value
+// if the Null value is not valid, and whether v is a bigquery.Null* value. Other values are returned as is.
+func bigQueryNullValue(v reflect.Value) (reflect.Value, bool) {
+	if !isBigQueryNullType(v.Type()) {
+		return v, false
+	}
+
+	// bigquery.Null* types consist of a value field followed by the Valid field
+	if !v.Field(1).Bool() {
+		return reflect.Value{}, true
+	}
+
+	return v.Field(0), true
+}
+
+// newBigQueryNull returns a valid bigquery.Null* value of type t holding inner
+func newBigQueryNull(t reflect.Type, inner reflect.Value) reflect.Value {
+	v := reflect.New(t).Elem()
+	v.Field(0).Set(inner)
+	v.Field(1).SetBool(true)
+
+	return v
+}
+
+// parseBool parses value as bool, accepting English and Dutch notations as well as 1/0
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "t", "1", "yes", "y", "ja", "j", "waar":
+		return true, nil
+	case "false", "f", "0", "no", "n", "nee", "onwaar":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("invalid boolean '%s'", value)
+}
+
+// parseTime parses value using the first layout that succeeds
+func parseTime(value string, layouts []string) (time.Time, error) {
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		t, err = time.Parse(layout, value)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, err
+}
+
+// parseTimeInLocation parses value using the first layout that succeeds,
+// interpreting values without time zone in location
+func parseTimeInLocation(value string, layouts []string, location *time.Location) (time.Time, error) {
+	var err error
+	for _, layout := range layouts {
+		var t time.Time
+		t, err = time.ParseInLocation(layout, value, location)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, err
+}
+
+func timestampParseLayouts(fieldLayouts *FieldLayouts) []string {
+	if fieldLayouts != nil {
+		if fieldLayouts.TimestampLayout != nil {
+			return []string{*fieldLayouts.TimestampLayout}
+		}
+		// SetStructFieldByTagWithFieldLayouts used to parse time.Time using TimeLayout,
+		// which is meant for civil.Time now
+		if fieldLayouts.TimeLayout != nil {
+			return []string{*fieldLayouts.TimeLayout, defaultTimestampLayout, time.RFC3339Nano}
+		}
+	}
+
+	return []string{defaultTimestampLayout, time.RFC3339Nano}
+}
+
+func dateParseLayouts(fieldLayouts *FieldLayouts) []string {
+	if fieldLayouts != nil {
+		if fieldLayouts.DateLayout != nil {
+			return []string{*fieldLayouts.DateLayout}
+		}
+	}
+
+	return []string{defaultDateLayout, "2006-01-02"}
+}
+
+func timeParseLayouts(fieldLayouts *FieldLayouts) []string {
+	if fieldLayouts != nil {
+		if fieldLayouts.TimeLayout != nil {
+			return []string{*fieldLayouts.TimeLayout}
+		}
+	}
+
+	return []string{defaultTimeLayout, "15:04:05.999999999", "15:04"}
+}
+
+func dateTimeParseLayouts(fieldLayouts *FieldLayouts) []string {
+	if fieldLayouts != nil {
+		if fieldLayouts.TimestampLayout != nil {
+			return []string{*fieldLayouts.TimestampLayout}
+		}
+	}
+
+	return []string{defaultTimestampLayout, "2006-01-02T15:04:05.999999999"}
+}
+
+// formatValue formats v as string, the counterpart of parseString
+func formatValue(v reflect.Value, fieldLayouts *FieldLayouts) (string, error) {
+	return formatValueWithTimestampLayout(v, fieldLayouts, defaultTimestampLayout)
+}
+
+// formatValueWithTimestampLayout formats v like formatValue, formatting time.Time
+// using timestampDefault if TimestampLayout is not set
+func formatValueWithTimestampLayout(v reflect.Value, fieldLayouts *FieldLayouts, timestampDefault string) (string, error) {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+
+		return formatValueWithTimestampLayout(v.Elem(), fieldLayouts, timestampDefault)
+	}
+
+	if formatter := registry.formatter(v.Type()); formatter != nil {
+		return formatter(v.Interface(), fieldLayouts)
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		// without Location, values keep their own zone as before
+		if fieldLayouts != nil && fieldLayouts.Location != nil {
+			value = value.In(fieldLayouts.Location)
+		}
+		return value.Format(timestampLayout(fieldLayouts, timestampDefault)), nil
+	case civil.Date:
+		if value.IsZero() {
+			return "", nil
+		}
+		return DateToTime(value).Format(dateLayout(fieldLayouts)), nil
+	case civil.Time:
+		if fieldLayouts != nil && fieldLayouts.TimeLayout != nil {
+			return time.Date(0, 1, 1, value.Hour, value.Minute, value.Second, value.Nanosecond, time.UTC).Format(*fieldLayouts.TimeLayout), nil
+		}
+		return value.String(), nil
+	case civil.DateTime:
+		if value.IsZero() {
+			return "", nil
+		}
+		return value.In(time.UTC).Format(timestampLayout(fieldLayouts, defaultTimestampLayout)), nil
+	}
+
+	if inner, ok := bigQueryNullValue(v); ok {
+		if !inner.IsValid() {
+			return "", nil
+		}
+
+		return formatValueWithTimestampLayout(inner, fieldLayouts, timestampDefault)
+	}
+
+	if textMarshaler, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := textMarshaler.MarshalText()
+		return string(b), err
+	}
+
+	if isBasicKind(v.Kind()) {
+		return formatBasic(v, fieldLayouts), nil
+	}
+
+	if stringer, ok := v.Interface().(fmt.Stringer); ok {
+		return stringer.String(), nil
+	}
+
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return "", err
+	}
+
+	// unquote json strings, see parseString
+	var s string
+	if json.Unmarshal(b, &s) == nil {
+		return s, nil
+	}
+
+	return string(b), nil
+}
+
+func timestampLayout(fieldLayouts *FieldLayouts, defaultLayout string) string {
+	if fieldLayouts != nil {
+		if fieldLayouts.TimestampLayout != nil {
+			return *fieldLayouts.TimestampLayout
+		}
+	}
+
+	return defaultLayout
+}
+
+func dateLayout(fieldLayouts *FieldLayouts) string {
+	if fieldLayouts != nil {
+		if fieldLayouts.DateLayout != nil {
+			return *fieldLayouts.DateLayout
+		}
+	}
+
+	return defaultDateLayout
+}
+
+func (fieldLayouts *FieldLayouts) location() *time.Location {
+	if fieldLayouts == nil || fieldLayouts.Location == nil {
+		return time.UTC
+	}
+
+	return fieldLayouts.Location
+}
+
+func (fieldLayouts *FieldLayouts) precision() int {
+	if fieldLayouts == nil || fieldLayouts.Precision == nil {
+		return -1
+	}
+
+	return *fieldLayouts.Precision
+}
+
+func (fieldLayouts *FieldLayouts) formatBool(b bool) string {
+	if b {
+		if fieldLayouts != nil && fieldLayouts.TrueValue != nil {
+			return *fieldLayouts.TrueValue
+		}
+		return "TRUE"
+	}
+
+	if fieldLayouts != nil && fieldLayouts.FalseValue != nil {
+		return *fieldLayouts.FalseValue
+	}
+	return "FALSE"
+}
+
+// parseBool parses value as bool, accepting TrueValue and FalseValue besides the default notations
+func (fieldLayouts *FieldLayouts) parseBool(value string) (bool, error) {
+	if fieldLayouts != nil {
+		if fieldLayouts.TrueValue != nil && value == *fieldLayouts.TrueValue {
+			return true, nil
+		}
+		if fieldLayouts.FalseValue != nil && value == *fieldLayouts.FalseValue {
+			return false, nil
+		}
+	}
+
+	return parseBool(value)
+}
+
+// formatNumber applies the separators to number, as formatted by strconv
+func (fieldLayouts *FieldLayouts) formatNumber(number string) string {
+	if fieldLayouts == nil {
+		return number
+	}
+
+	integer, fraction, hasFraction := strings.Cut(number, ".")
+
+	if fieldLayouts.ThousandsSeparator != nil {
+		sign := ""
+		if strings.HasPrefix(integer, "-") {
+			sign, integer = "-", integer[1:]
+		}
+		for i := len(integer) - 3; i > 0; i -= 3 {
+			integer = integer[:i] + *fieldLayouts.ThousandsSeparator + integer[i:]
+		}
+		integer = sign + integer
+	}
+
+	if !hasFraction {
+		return integer
+	}
+
+	decimalSeparator := "."
+	if fieldLayouts.DecimalSeparator != nil {
+		decimalSeparator = *fieldLayouts.DecimalSeparator
+	}
+
+	return integer + decimalSeparator + fraction
+}
+
+// normalizeNumber removes the separators from number so it can be parsed by strconv
+func (fieldLayouts *FieldLayouts) normalizeNumber(number string) string {
+	if fieldLayouts == nil {
+		return number
+	}
+
+	if fieldLayouts.ThousandsSeparator != nil && *fieldLayouts.ThousandsSeparator != "" {
+		number = strings.ReplaceAll(number, *fieldLayouts.ThousandsSeparator, "")
+	}
+	if fieldLayouts.DecimalSeparator != nil && *fieldLayouts.DecimalSeparator != "" {
+		number = strings.Replace(number, *fieldLayouts.DecimalSeparator, ".", 1)
+	}
+
+	return number
+}
+
+// withoutSeparators returns a copy of fieldLayouts without DecimalSeparator and ThousandsSeparator
+func (fieldLayouts *FieldLayouts) withoutSeparators() *FieldLayouts {
+	if fieldLayouts == nil || (fieldLayouts.DecimalSeparator == nil && fieldLayouts.ThousandsSeparator == nil) {
+		return fieldLayouts
+	}
+
+	_fieldLayouts := *fieldLayouts
+	_fieldLayouts.DecimalSeparator = nil
+	_fieldLayouts.ThousandsSeparator = nil
+
+	return &_fieldLayouts
+}
+
+// convertValue converts value to type t, parsing strings and converting numbers
+// as long as no information is lost, e.g. float64 3 to int but not 3.5
+func convertValue(value interface{}, t reflect.Type, fieldLayouts *FieldLayouts) (reflect.Value, error) {
+	if value == nil {
+		return reflect.Zero(t), nil
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Type().AssignableTo(t) {
+		return v, nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Zero(t), nil
+		}
+		return convertValue(v.Elem().Interface(), t, fieldLayouts)
+	}
+
+	if t.Kind() == reflect.Ptr {
+		inner, err := convertValue(value, t.Elem(), fieldLayouts)
+		if err != nil {
+			return inner, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(inner)
+		return p, nil
+	}
+
+	if isBigQueryNullType(t) {
+		inner, err := convertValue(value, t.Field(0).Type, fieldLayouts)
+		if err != nil {
+			return inner, err
+		}
+		return newBigQueryNull(t, inner), nil
+	}
+
+	if number, ok := value.(json.Number); ok {
+		// JSON numbers have no locale specific separators
+		return parseString(number.String(), t, fieldLayouts.withoutSeparators())
+	}
+
+	if v.Kind() == reflect.String {
+		return parseString(v.String(), t, fieldLayouts)
+	}
+
+	if t.Kind() == reflect.String {
+		s, err := formatValue(v, fieldLayouts)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(s).Convert(t), nil
+	}
+
+	cannotConvert := fmt.Errorf("cannot convert %T %v to %s", value, value, t)
+
+	r := reflect.New(t).Elem()
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if r.OverflowInt(v.Int()) {
+				return r, cannotConvert
+			}
+			r.SetInt(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if v.Uint() > math.MaxInt64 || r.OverflowInt(int64(v.Uint())) {
+				return r, cannotConvert
+			}
+			r.SetInt(int64(v.Uint()))
+		case reflect.Float32, reflect.Float64:
+			f := v.Float()
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || r.OverflowInt(int64(f)) {
+				return r, cannotConvert
+			}
+			r.SetInt(int64(f))
+		default:
+			return r, cannotConvert
+		}
+		return r, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.Int() < 0 || r.OverflowUint(uint64(v.Int())) {
+				return r, cannotConvert
+			}
+			r.SetUint(uint64(v.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if r.OverflowUint(v.Uint()) {
+				return r, cannotConvert
+			}
+			r.SetUint(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			f := v.Float()
+			if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 || r.OverflowUint(uint64(f)) {
+				return r, cannotConvert
+			}
+			r.SetUint(uint64(f))
+		default:
+			return r, cannotConvert
+		}
+		return r, nil
+	case reflect.Float32, reflect.Float64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			r.SetFloat(float64(v.Int()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			r.SetFloat(float64(v.Uint()))
+		case reflect.Float32, reflect.Float64:
+			if r.OverflowFloat(v.Float()) {
+				return r, cannotConvert
+			}
+			r.SetFloat(v.Float())
+		default:
+			return r, cannotConvert
+		}
+		return r, nil
+	}
+
+	if v.Kind() == t.Kind() && v.Type().ConvertibleTo(t) {
+		return v.Convert(t), nil
+	}
+
+	return r, cannotConvert
+}
@@ -0,0 +1,167 @@
+package utilities
+
+import (
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fieldValueRow struct {
+	Int       int                  `csv:"int"`
+	Int8      int8                 `csv:"int8"`
+	Uint16    uint16               `csv:"uint16"`
+	Float32   float32              `csv:"float32"`
+	Bool      bool                 `csv:"bool"`
+	IntPtr    *int                 `csv:"int_ptr"`
+	Time      time.Time            `csv:"time"`
+	Date      civil.Date           `csv:"date"`
+	NullInt   bigquery.NullInt64   `csv:"null_int"`
+	NullDate  bigquery.NullDate    `csv:"null_date"`
+	NullFloat bigquery.NullFloat64 `csv:"null_float"`
+}
+
+func TestStringArrayToStructTypes(t *testing.T) {
+	records := [][]string{
+		{"int", "int8", "uint16", "float32", "bool", "int_ptr", "time", "date", "null_int", "null_date", "null_float"},
+		{"-12", "127", "65535", "1.5", "ja", "7", "2024-01-02 10:11:12", "02-01-2024", "42", "2024-01-02", ""},
+	}
+
+	var rows []fieldValueRow
+	fieldErrors, e := StringArrayToStructWithOptions(&records, &rows, nil)
+	if e != nil || len(fieldErrors) > 0 {
+		t.Fatalf("error %v, field errors %v", e, fieldErrors)
+	}
+
+	seven := 7
+	expected := fieldValueRow{
+		Int:      -12,
+		Int8:     127,
+		Uint16:   65535,
+		Float32:  1.5,
+		Bool:     true,
+		IntPtr:   &seven,
+		Time:     time.Date(2024, 1, 2, 10, 11, 12, 0, time.UTC),
+		Date:     civil.Date{Year: 2024, Month: 1, Day: 2},
+		NullInt:  bigquery.NullInt64{Int64: 42, Valid: true},
+		NullDate: bigquery.NullDate{Date: civil.Date{Year: 2024, Month: 1, Day: 2}, Valid: true},
+	}
+	if len(rows) != 1 || !reflect.DeepEqual(rows[0], expected) {
+		t.Fatalf("got %+v, expected %+v", rows, expected)
+	}
+}
+
+func TestStringArrayToStructOverflow(t *testing.T) {
+	records := [][]string{
+		{"int8", "uint16"},
+		{"128", "-1"},
+	}
+
+	var rows []fieldValueRow
+	fieldErrors, e := StringArrayToStructWithOptions(&records, &rows, nil)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	if len(fieldErrors) != 2 {
+		t.Fatalf("got field errors %v, expected 2", fieldErrors)
+	}
+}
+
+func TestBigQueryNullValue(t *testing.T) {
+	v, ok := bigQueryNullValue(reflect.ValueOf(bigquery.NullString{StringVal: "a", Valid: true}))
+	if !ok || v.Interface() != "a" {
+		t.Fatalf("got %v, %v", v, ok)
+	}
+
+	v, ok = bigQueryNullValue(reflect.ValueOf(bigquery.NullString{StringVal: "a"}))
+	if !ok || v.IsValid() {
+		t.Fatalf("got %v, %v for invalid Null value", v, ok)
+	}
+
+	v, ok = bigQueryNullValue(reflect.ValueOf("a"))
+	if ok || v.Interface() != "a" {
+		t.Fatalf("got %v, %v for string", v, ok)
+	}
+
+	s, err := formatValue(reflect.ValueOf(bigquery.NullInt64{Int64: 3, Valid: true}), nil)
+	if err != nil || s != "3" {
+		t.Fatalf("formatted '%s', error %v", s, err)
+	}
+	s, err = formatValue(reflect.ValueOf(bigquery.NullInt64{Int64: 3}), nil)
+	if err != nil || s != "" {
+		t.Fatalf("formatted invalid Null value as '%s', error %v", s, err)
+	}
+}
+
+func TestFormatValueLocation(t *testing.T) {
+	value := reflect.ValueOf(time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)))
+	layout := "15:04:05"
+
+	for _, test := range []struct {
+		location *time.Location
+		expected string
+	}{
+		{nil, "03:04:05"},
+		{time.UTC, "02:04:05"},
+		{time.FixedZone("EET", 7200), "04:04:05"},
+	} {
+		s, err := formatValue(value, &FieldLayouts{TimestampLayout: &layout, Location: test.location})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != test.expected {
+			t.Fatalf("formatted %s in %v, expected %s", s, test.location, test.expected)
+		}
+	}
+
+	// values without zone are parsed in Location
+	location := time.FixedZone("CET", 3600)
+	var row fieldValueRow
+	if err := setStructFieldFromString(reflect.ValueOf(&row).Elem().FieldByName("Time"), "2024-01-02 03:04:05", &FieldLayouts{Location: location}); err != nil {
+		t.Fatal(err)
+	}
+	if !row.Time.Equal(time.Date(2024, 1, 2, 2, 4, 5, 0, time.UTC)) {
+		t.Fatalf("parsed %v", row.Time)
+	}
+}
+
+func TestFieldLayoutsLocale(t *testing.T) {
+	decimalSeparator, thousandsSeparator := ",", "."
+	precision := 2
+	trueValue, falseValue := "ja", "nee"
+	fieldLayouts := &FieldLayouts{
+		DecimalSeparator:   &decimalSeparator,
+		ThousandsSeparator: &thousandsSeparator,
+		Precision:          &precision,
+		TrueValue:          &trueValue,
+		FalseValue:         &falseValue,
+	}
+
+	for value, expected := range map[interface{}]string{
+		1234567.891: "1.234.567,89",
+		-1234.5:     "-1.234,50",
+		1234567:     "1.234.567",
+		true:        "ja",
+		false:       "nee",
+	} {
+		s, err := formatValue(reflect.ValueOf(value), fieldLayouts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != expected {
+			t.Fatalf("formatted %v as %s, expected %s", value, s, expected)
+		}
+	}
+
+	var row fieldValueRow
+	v := reflect.ValueOf(&row).Elem()
+	for field, value := range map[string]string{"Int": "1.234", "Float32": "1.234,5", "Bool": "ja"} {
+		if err := setStructFieldFromString(v.FieldByName(field), value, fieldLayouts); err != nil {
+			t.Fatalf("%s: %v", field, err)
+		}
+	}
+	if row.Int != 1234 || row.Float32 != 1234.5 || !row.Bool {
+		t.Fatalf("parsed %+v", row)
+	}
+}
@@ -0,0 +1,359 @@
+package utilities
+
+import (
+	"bufio"
+	"fmt"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const fixedWidthTag string = "fixed"
+
+// FixedWidthOptions holds the options used when mapping fixed-width lines to and from structs
+//
+// Fields are mapped by their `fixed` tag: `fixed:"start,length,align,pad"`, start being the
+// 1-based position of the first character, align either left (default) or right and pad
+// the padding character (default space), e.g. `fixed:"11,8,right,0"`. Decoding removes the
+// padding, encoding pads values and fails for values exceeding their length.
+//
+// In Strict mode decoding aborts at the first value that cannot be assigned to its field,
+// otherwise all invalid values are collected as FieldErrors.
+type FixedWidthOptions struct {
+	FieldLayouts *FieldLayouts
+	Strict       bool
+}
+
+func (options *FixedWidthOptions) fieldLayouts() *FieldLayouts {
+	if options == nil {
+		return nil
+	}
+
+	return options.FieldLayouts
+}
+
+func (options *FixedWidthOptions) strict() bool {
+	if options == nil {
+		return false
+	}
+
+	return options.Strict
+}
+
+// fixedWidthField is a struct field mapped to a range of characters
+type fixedWidthField struct {
+	index      []int
+	fieldName  string
+	start      int // 0-based
+	length     int
+	alignRight bool
+	pad        string
+}
+
+// column returns the 1-based range of the field as used in errors, e.g. 11-18
+func (field *fixedWidthField) column() string {
+	return fmt.Sprintf("%v-%v", field.start+1, field.start+field.length)
+}
+
+// fixedWidthFields returns the fields of structType having a `fixed` tag and the line width
+func fixedWidthFields(structType reflect.Type) ([]fixedWidthField, int, error) {
+	fields := []fixedWidthField{}
+	width := 0
+
+	for _, field := range structFields(structType, fixedWidthTag, false, nil) {
+		parts := strings.Split(field.field.Tag.Get(fixedWidthTag), ",")
+		if len(parts) < 2 {
+			return nil, 0, fmt.Errorf("field %s: tag should at least hold start and length", field.fieldName)
+		}
+
+		start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || start < 1 {
+			return nil, 0, fmt.Errorf("field %s: invalid start '%s'", field.fieldName, parts[0])
+		}
+
+		length, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || length < 1 {
+			return nil, 0, fmt.Errorf("field %s: invalid length '%s'", field.fieldName, parts[1])
+		}
+
+		_field := fixedWidthField{
+			index:     field.index,
+			fieldName: field.fieldName,
+			start:     start - 1,
+			length:    length,
+			pad:       " ",
+		}
+
+		if len(parts) > 2 {
+			switch strings.TrimSpace(parts[2]) {
+			case "", "left":
+			case "right":
+				_field.alignRight = true
+			default:
+				return nil, 0, fmt.Errorf("field %s: invalid align '%s'", field.fieldName, parts[2])
+			}
+		}
+
+		if len(parts) > 3 && parts[3] != "" {
+			if utf8.RuneCountInString(parts[3]) != 1 {
+				return nil, 0, fmt.Errorf("field %s: invalid pad '%s'", field.fieldName, parts[3])
+			}
+			_field.pad = parts[3]
+		}
+
+		if start-1+length > width {
+			width = start - 1 + length
+		}
+
+		fields = append(fields, _field)
+	}
+
+	return fields, width, nil
+}
+
+// FixedWidthDecoder reads lines from an io.Reader and decodes them one at a time into structs
+//
+// Usage:
+//
+//	decoder := NewFixedWidthDecoder(reader, nil)
+//	for decoder.Next() {
+//		var line Line
+//		if e := decoder.Decode(&line); e != nil {
+//			return e
+//		}
+//	}
+//	if e := decoder.Err(); e != nil {
+//		return e
+//	}
+type FixedWidthDecoder struct {
+	scanner     *bufio.Scanner
+	options     *FixedWidthOptions
+	line        []rune
+	lineNumber  int
+	structType  reflect.Type
+	fields      []fixedWidthField
+	fieldErrors FieldErrors
+	err         *errortools.Error
+}
+
+func NewFixedWidthDecoder(reader io.Reader, options *FixedWidthOptions) *FixedWidthDecoder {
+	return &FixedWidthDecoder{
+		scanner: bufio.NewScanner(reader),
+		options: options,
+	}
+}
+
+// Next advances the decoder to the next line.
+// It returns false when there are no more lines or an error occurred, see Err.
+func (decoder *FixedWidthDecoder) Next() bool {
+	decoder.line = nil
+
+	if decoder.err != nil {
+		return false
+	}
+
+	if !decoder.scanner.Scan() {
+		err := decoder.scanner.Err()
+		if err != nil {
+			decoder.err = errortools.ErrorMessage(err)
+		}
+		return false
+	}
+
+	decoder.lineNumber++
+	decoder.line = []rune(strings.TrimRight(decoder.scanner.Text(), "\r"))
+
+	return true
+}
+
+// Decode decodes the current line into model, which must be a pointer to a struct.
+// In strict mode the first invalid value is returned as error, otherwise invalid values
+// are skipped and collected, see FieldErrors.
+func (decoder *FixedWidthDecoder) Decode(model interface{}) *errortools.Error {
+	if decoder.line == nil {
+		return errortools.ErrorMessage("No current line, call Next first.")
+	}
+
+	if reflect.TypeOf(model).Kind() != reflect.Ptr {
+		return errortools.ErrorMessage("The interface is not a pointer.")
+	}
+
+	v := reflect.ValueOf(model).Elem()
+	if v.Kind() != reflect.Struct {
+		return errortools.ErrorMessage("The interface is not a pointer to a struct.")
+	}
+
+	if decoder.structType != v.Type() {
+		fields, _, err := fixedWidthFields(v.Type())
+		if err != nil {
+			return errortools.ErrorMessage(err)
+		}
+
+		decoder.structType = v.Type()
+		decoder.fields = fields
+	}
+
+	for _, field := range decoder.fields {
+		value := ""
+		if field.start < len(decoder.line) {
+			end := field.start + field.length
+			if end > len(decoder.line) {
+				end = len(decoder.line)
+			}
+			value = string(decoder.line[field.start:end])
+		}
+
+		if field.alignRight {
+			value = strings.TrimLeft(value, field.pad)
+		} else {
+			value = strings.TrimRight(value, field.pad)
+		}
+		value = strings.TrimSpace(value)
+
+		err := setStructFieldFromString(v.FieldByIndex(field.index), value, decoder.options.fieldLayouts())
+		if err != nil {
+			fieldError := &FieldError{
+				Row:    decoder.lineNumber,
+				Column: field.column(),
+				Field:  field.fieldName,
+				Value:  value,
+				Err:    err,
+			}
+			decoder.fieldErrors = append(decoder.fieldErrors, fieldError)
+
+			if decoder.options.strict() {
+				return errortools.ErrorMessage(fieldError)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Line returns the 1-based number of the current line
+func (decoder *FixedWidthDecoder) Line() int {
+	return decoder.lineNumber
+}
+
+// FieldErrors returns the invalid values collected by Decode so far
+func (decoder *FixedWidthDecoder) FieldErrors() FieldErrors {
+	return decoder.fieldErrors
+}
+
+// Err returns the first read error encountered by Next
+func (decoder *FixedWidthDecoder) Err() *errortools.Error {
+	return decoder.err
+}
+
+// FixedWidthEncoder writes structs as fixed-width lines to an io.Writer
+type FixedWidthEncoder struct {
+	writer     *bufio.Writer
+	options    *FixedWidthOptions
+	lineNumber int
+	structType reflect.Type
+	fields     []fixedWidthField
+	width      int
+}
+
+func NewFixedWidthEncoder(writer io.Writer, options *FixedWidthOptions) *FixedWidthEncoder {
+	return &FixedWidthEncoder{
+		writer:  bufio.NewWriter(writer),
+		options: options,
+	}
+}
+
+// Encode writes model, a struct or pointer to a struct, as line.
+// Characters not covered by any field are written as spaces.
+func (encoder *FixedWidthEncoder) Encode(model interface{}) *errortools.Error {
+	v := reflect.ValueOf(model)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return errortools.ErrorMessage("The interface is not a (pointer to a) struct.")
+	}
+
+	if encoder.structType == nil {
+		fields, width, err := fixedWidthFields(v.Type())
+		if err != nil {
+			return errortools.ErrorMessage(err)
+		}
+
+		encoder.structType = v.Type()
+		encoder.fields = fields
+		encoder.width = width
+	} else if encoder.structType != v.Type() {
+		return errortools.ErrorMessagef("Cannot encode %s, encoder is set up for %s.", v.Type(), encoder.structType)
+	}
+
+	encoder.lineNumber++
+
+	line := []rune(strings.Repeat(" ", encoder.width))
+
+	for _, field := range encoder.fields {
+		value, err := formatValue(v.FieldByIndex(field.index), encoder.options.fieldLayouts())
+		if err == nil && utf8.RuneCountInString(value) > field.length {
+			err = fmt.Errorf("value exceeds length %v", field.length)
+		}
+		if err != nil {
+			return errortools.ErrorMessage(&FieldError{
+				Row:    encoder.lineNumber,
+				Column: field.column(),
+				Field:  field.fieldName,
+				Value:  value,
+				Err:    err,
+			})
+		}
+
+		padding := strings.Repeat(field.pad, field.length-utf8.RuneCountInString(value))
+		if field.alignRight {
+			value = padding + value
+		} else {
+			value = value + padding
+		}
+
+		copy(line[field.start:], []rune(value))
+	}
+
+	_, err := encoder.writer.WriteString(string(line) + "\n")
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	return nil
+}
+
+// EncodeAll writes all elements of model, a slice or pointer to a slice of structs, and flushes the writer
+func (encoder *FixedWidthEncoder) EncodeAll(model interface{}) *errortools.Error {
+	v := reflect.ValueOf(model)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Slice {
+		return errortools.ErrorMessage("The interface is not a (pointer to a) slice.")
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		e := encoder.Encode(v.Index(i).Interface())
+		if e != nil {
+			return e
+		}
+	}
+
+	return encoder.Flush()
+}
+
+// Flush writes any buffered data to the underlying io.Writer
+func (encoder *FixedWidthEncoder) Flush() *errortools.Error {
+	err := encoder.writer.Flush()
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package utilities
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fixedWidthLine struct {
+	Code   string    `fixed:"1,6"`
+	Amount int       `fixed:"7,8,right,0"`
+	Price  float64   `fixed:"15,6,right"`
+	Date   time.Time `fixed:"22,10"`
+	Name   string    `fixed:"32,6"`
+}
+
+func decodeFixedWidth(t *testing.T, data string, options *FixedWidthOptions) ([]fixedWidthLine, *FixedWidthDecoder) {
+	t.Helper()
+
+	decoder := NewFixedWidthDecoder(strings.NewReader(data), options)
+
+	var lines []fixedWidthLine
+	for decoder.Next() {
+		var line fixedWidthLine
+		if e := decoder.Decode(&line); e != nil {
+			t.Fatal(e.Message())
+		}
+		lines = append(lines, line)
+	}
+	if e := decoder.Err(); e != nil {
+		t.Fatal(e.Message())
+	}
+
+	return lines, decoder
+}
+
+func TestFixedWidthRoundTrip(t *testing.T) {
+	dateLayout := "2006-01-02"
+	options := &FixedWidthOptions{FieldLayouts: &FieldLayouts{TimestampLayout: &dateLayout}}
+
+	lines := []fixedWidthLine{
+		{Code: "A1", Amount: 120, Price: 9.95, Date: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Name: "hamer"},
+		{Code: "B22", Amount: 7, Price: 100, Date: time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), Name: "zaag"},
+	}
+
+	var b bytes.Buffer
+	if e := NewFixedWidthEncoder(&b, options).EncodeAll(lines); e != nil {
+		t.Fatal(e.Message())
+	}
+
+	expected := "A1    00000120  9.95 2024-01-02hamer \n" +
+		"B22   00000007   100 2024-12-31zaag  \n"
+	if b.String() != expected {
+		t.Fatalf("encoded '%s', expected '%s'", b.String(), expected)
+	}
+
+	decoded, decoder := decodeFixedWidth(t, b.String(), options)
+	if !reflect.DeepEqual(decoded, lines) {
+		t.Fatalf("decoded %+v, expected %+v", decoded, lines)
+	}
+	if len(decoder.FieldErrors()) > 0 {
+		t.Fatalf("got field errors %v", decoder.FieldErrors())
+	}
+}
+
+func TestFixedWidthShortLines(t *testing.T) {
+	// missing trailing characters and carriage returns are accepted
+	decoded, _ := decodeFixedWidth(t, "A1    00000120\r\n", nil)
+
+	expected := []fixedWidthLine{{Code: "A1", Amount: 120}}
+	if !reflect.DeepEqual(decoded, expected) {
+		t.Fatalf("decoded %+v, expected %+v", decoded, expected)
+	}
+}
+
+func TestFixedWidthFieldErrors(t *testing.T) {
+	data := "A1    0000012x  9.95\nB2    00000007     x\n"
+
+	decoded, decoder := decodeFixedWidth(t, data, nil)
+	if len(decoded) != 2 || decoded[0].Price != 9.95 || decoded[1].Amount != 7 {
+		t.Fatalf("decoded %+v", decoded)
+	}
+
+	fieldErrors := decoder.FieldErrors()
+	if len(fieldErrors) != 2 || fieldErrors[0].Row != 1 || fieldErrors[0].Column != "7-14" || fieldErrors[1].Field != "Price" {
+		t.Fatalf("got field errors %v", fieldErrors)
+	}
+
+	decoder = NewFixedWidthDecoder(strings.NewReader(data), &FixedWidthOptions{Strict: true})
+	decoder.Next()
+	if e := decoder.Decode(&fixedWidthLine{}); e == nil {
+		t.Fatal("strict decoder accepted invalid value")
+	}
+}
+
+func TestFixedWidthEncoderExceedsLength(t *testing.T) {
+	encoder := NewFixedWidthEncoder(&bytes.Buffer{}, nil)
+	if e := encoder.Encode(fixedWidthLine{Code: "ABCDEFG"}); e == nil {
+		t.Fatal("encoded value exceeding its length")
+	}
+}
+
+func TestFixedWidthInvalidTag(t *testing.T) {
+	for _, model := range []interface{}{
+		&struct {
+			A string `fixed:"1"`
+		}{},
+		&struct {
+			A string `fixed:"0,2"`
+		}{},
+		&struct {
+			A string `fixed:"1,2,center"`
+		}{},
+		&struct {
+			A string `fixed:"1,2,left,ab"`
+		}{},
+	} {
+		decoder := NewFixedWidthDecoder(strings.NewReader("ab\n"), nil)
+		decoder.Next()
+		if e := decoder.Decode(model); e == nil {
+			t.Fatalf("accepted %T", model)
+		}
+	}
+}
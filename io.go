@@ -1,6 +1,13 @@
 package utilities
 
-import "os"
+import (
+	"bufio"
+	"bytes"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"io"
+	"os"
+)
 
 func FileExists(filename string) bool {
 	info, err := os.Stat(filename)
@@ -9,3 +16,58 @@ func FileExists(filename string) bool {
 	}
 	return !info.IsDir()
 }
+
+// TextEncoding is the character encoding of a text stream
+type TextEncoding int
+
+const (
+	// TextEncodingAuto detects UTF-8 and UTF-16 by their byte order mark, defaulting to UTF-8
+	TextEncodingAuto TextEncoding = iota
+	TextEncodingUtf8
+	TextEncodingUtf16LE
+	TextEncodingUtf16BE
+	TextEncodingWindows1252
+	TextEncodingIso88591
+)
+
+var (
+	utf8Bom    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBom = []byte{0xFF, 0xFE}
+	utf16BEBom = []byte{0xFE, 0xFF}
+)
+
+// NewUtf8Reader returns a reader transcoding reader from encoding to UTF-8.
+// A byte order mark at the start of the stream is removed, other bytes are left untouched.
+func NewUtf8Reader(reader io.Reader, encoding TextEncoding) io.Reader {
+	bufferedReader := bufio.NewReader(reader)
+
+	if encoding == TextEncodingAuto {
+		encoding = TextEncodingUtf8
+
+		// Peek returns fewer bytes along with an error for short streams
+		prefix, _ := bufferedReader.Peek(len(utf8Bom))
+		if bytes.HasPrefix(prefix, utf16LEBom) {
+			encoding = TextEncodingUtf16LE
+		} else if bytes.HasPrefix(prefix, utf16BEBom) {
+			encoding = TextEncodingUtf16BE
+		}
+	}
+
+	switch encoding {
+	case TextEncodingUtf16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder().Reader(bufferedReader)
+	case TextEncodingUtf16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder().Reader(bufferedReader)
+	case TextEncodingWindows1252:
+		return charmap.Windows1252.NewDecoder().Reader(bufferedReader)
+	case TextEncodingIso88591:
+		return charmap.ISO8859_1.NewDecoder().Reader(bufferedReader)
+	}
+
+	prefix, _ := bufferedReader.Peek(len(utf8Bom))
+	if bytes.Equal(prefix, utf8Bom) {
+		bufferedReader.Discard(len(utf8Bom))
+	}
+
+	return bufferedReader
+}
@@ -0,0 +1,67 @@
+package utilities
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func readUtf8(t *testing.T, data []byte, encoding TextEncoding) string {
+	t.Helper()
+
+	b, err := io.ReadAll(NewUtf8Reader(bytes.NewReader(data), encoding))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestNewUtf8Reader(t *testing.T) {
+	for _, test := range []struct {
+		data     []byte
+		encoding TextEncoding
+		expected string
+	}{
+		{[]byte("id,naam\n"), TextEncodingAuto, "id,naam\n"},
+		{[]byte("\xEF\xBB\xBFid"), TextEncodingAuto, "id"},
+		{[]byte("\xEF\xBB\xBFid"), TextEncodingUtf8, "id"},
+		{[]byte("\xFF\xFEi\x00\xEB\x00"), TextEncodingAuto, "ië"},
+		{[]byte("\xFE\xFF\x00i\x00\xEB"), TextEncodingAuto, "ië"},
+		{[]byte("i\x00\xEB\x00"), TextEncodingUtf16LE, "ië"},
+		{[]byte("caf\xE9 \x80"), TextEncodingWindows1252, "café €"},
+		{[]byte("caf\xE9"), TextEncodingIso88591, "café"},
+		{[]byte(""), TextEncodingAuto, ""},
+		{[]byte("\xEF"), TextEncodingAuto, "\xEF"},
+	} {
+		if s := readUtf8(t, test.data, test.encoding); s != test.expected {
+			t.Fatalf("read %q from %q, expected %q", s, test.data, test.expected)
+		}
+	}
+}
+
+func TestCsvDecoderEncoding(t *testing.T) {
+	data := []byte("\xFF\xFEi\x00d\x00,\x00n\x00a\x00m\x00e\x00\n\x001\x00,\x00\xEB\x00\n\x00")
+
+	decoder := NewCsvDecoder(bytes.NewReader(data), nil)
+	if !decoder.Next() {
+		t.Fatalf("no record, error %v", decoder.Err())
+	}
+
+	var row decoderRow
+	if e := decoder.Decode(&row); e != nil {
+		t.Fatal(e.Message())
+	}
+	if row != (decoderRow{Id: 1, Name: "ë"}) {
+		t.Fatalf("got %+v", row)
+	}
+
+	records := [][]string{{"\uFEFFid", "name"}, {"1", "alice"}}
+	var rows []decoderRow
+	if e := StringArrayToStruct(&records, &rows); e != nil {
+		t.Fatal(e.Message())
+	}
+	if len(rows) != 1 || rows[0].Id != 1 {
+		t.Fatalf("got %+v", rows)
+	}
+}
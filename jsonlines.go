@@ -0,0 +1,295 @@
+package utilities
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"io"
+	"reflect"
+)
+
+const defaultJsonLinesTag string = "json"
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// JsonLinesOptions holds the options used when mapping structs to and from JSON Lines (NDJSON).
+// Fields are named after Tag (default "json"), see StructToMap.
+// FieldLayouts, if set, are used for time.Time and civil values, otherwise these are written
+// as by encoding/json (RFC 3339 and 2006-01-02).
+// Gzip compresses the output of JsonLinesEncoder, JsonLinesDecoder detects gzip input itself.
+// In Strict mode decoding fails at the first value that cannot be assigned to its field.
+type JsonLinesOptions struct {
+	Tag          *string
+	FieldLayouts *FieldLayouts
+	Gzip         bool
+	Strict       bool
+}
+
+func (options *JsonLinesOptions) mapOptions() *MapOptions {
+	tag := defaultJsonLinesTag
+	mapOptions := MapOptions{Tag: &tag}
+
+	if options != nil {
+		if options.Tag != nil {
+			mapOptions.Tag = options.Tag
+		}
+		mapOptions.FieldLayouts = options.FieldLayouts
+	}
+
+	return &mapOptions
+}
+
+func (options *JsonLinesOptions) gzip() bool {
+	if options == nil {
+		return false
+	}
+
+	return options.Gzip
+}
+
+func (options *JsonLinesOptions) strict() bool {
+	if options == nil {
+		return false
+	}
+
+	return options.Strict
+}
+
+// JsonLinesEncoder writes structs as JSON objects, one per line, to an io.Writer
+type JsonLinesEncoder struct {
+	writer     *bufio.Writer
+	gzipWriter *gzip.Writer
+	options    *JsonLinesOptions
+	mapOptions *MapOptions
+	row        int
+}
+
+func NewJsonLinesEncoder(writer io.Writer, options *JsonLinesOptions) *JsonLinesEncoder {
+	encoder := JsonLinesEncoder{
+		options:    options,
+		mapOptions: options.mapOptions(),
+	}
+
+	if options.gzip() {
+		encoder.gzipWriter = gzip.NewWriter(writer)
+		writer = encoder.gzipWriter
+	}
+
+	encoder.writer = bufio.NewWriter(writer)
+
+	return &encoder
+}
+
+// Encode writes model, a (pointer to a) struct, as line
+func (encoder *JsonLinesEncoder) Encode(model interface{}) *errortools.Error {
+	encoder.row++
+
+	m, e := StructToMap(model, encoder.mapOptions)
+	if e != nil {
+		return errortools.ErrorMessagef("Row %v: %s", encoder.row, e.Message())
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return errortools.ErrorMessagef("Row %v: %s", encoder.row, err.Error())
+	}
+
+	_, err = encoder.writer.Write(append(b, '\n'))
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	return nil
+}
+
+// EncodeAll writes all elements of model, a slice or pointer to a slice of structs, and flushes the writer
+func (encoder *JsonLinesEncoder) EncodeAll(model interface{}) *errortools.Error {
+	v := reflect.ValueOf(model)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Slice {
+		return errortools.ErrorMessage("The interface is not a (pointer to a) slice.")
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		e := encoder.Encode(v.Index(i).Interface())
+		if e != nil {
+			return e
+		}
+	}
+
+	return encoder.Flush()
+}
+
+// Flush writes any buffered data to the underlying io.Writer
+func (encoder *JsonLinesEncoder) Flush() *errortools.Error {
+	err := encoder.writer.Flush()
+	if err != nil {
+		return errortools.ErrorMessage(err)
+	}
+
+	if encoder.gzipWriter != nil {
+		err = encoder.gzipWriter.Flush()
+		if err != nil {
+			return errortools.ErrorMessage(err)
+		}
+	}
+
+	return nil
+}
+
+// Close flushes the encoder and, if Gzip is set, completes the gzip stream.
+// It does not close the underlying io.Writer.
+func (encoder *JsonLinesEncoder) Close() *errortools.Error {
+	e := encoder.Flush()
+	if e != nil {
+		return e
+	}
+
+	if encoder.gzipWriter != nil {
+		err := encoder.gzipWriter.Close()
+		if err != nil {
+			return errortools.ErrorMessage(err)
+		}
+	}
+
+	return nil
+}
+
+// JsonLinesDecoder reads JSON objects, one per line, from an io.Reader and decodes them
+// one at a time into structs. Empty lines are skipped.
+//
+// Usage:
+//
+//	decoder := NewJsonLinesDecoder(reader, nil)
+//	for decoder.Next() {
+//		var row Row
+//		if e := decoder.Decode(&row); e != nil {
+//			return e
+//		}
+//	}
+//	if e := decoder.Err(); e != nil {
+//		return e
+//	}
+type JsonLinesDecoder struct {
+	reader      *bufio.Reader
+	options     *JsonLinesOptions
+	mapOptions  *MapOptions
+	line        []byte
+	row         int
+	fieldErrors FieldErrors
+	err         *errortools.Error
+}
+
+func NewJsonLinesDecoder(reader io.Reader, options *JsonLinesOptions) *JsonLinesDecoder {
+	decoder := JsonLinesDecoder{
+		options:    options,
+		mapOptions: options.mapOptions(),
+	}
+
+	bufferedReader := bufio.NewReader(reader)
+
+	prefix, _ := bufferedReader.Peek(len(gzipMagic))
+	if bytes.Equal(prefix, gzipMagic) {
+		gzipReader, err := gzip.NewReader(bufferedReader)
+		if err != nil {
+			decoder.err = errortools.ErrorMessage(err)
+		} else {
+			bufferedReader = bufio.NewReader(gzipReader)
+		}
+	}
+
+	decoder.reader = bufferedReader
+
+	return &decoder
+}
+
+// Next advances the decoder to the next non-empty line.
+// It returns false when there are no more lines or an error occurred, see Err.
+func (decoder *JsonLinesDecoder) Next() bool {
+	decoder.line = nil
+
+	for decoder.err == nil {
+		line, err := decoder.reader.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			decoder.err = errortools.ErrorMessage(err)
+			return false
+		}
+
+		if len(line) > 0 {
+			decoder.row++
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			decoder.line = line
+			return true
+		}
+
+		if err != nil {
+			// io.EOF
+			return false
+		}
+	}
+
+	return false
+}
+
+// Decode decodes the current line into model, which must be a pointer to a struct.
+// Lines that are not valid JSON objects are returned as error, mentioning the row.
+// In strict mode the first invalid value is returned as error as well, otherwise invalid
+// values are skipped and collected, see FieldErrors.
+func (decoder *JsonLinesDecoder) Decode(model interface{}) *errortools.Error {
+	if decoder.line == nil {
+		return errortools.ErrorMessage("No current line, call Next first.")
+	}
+
+	jsonDecoder := json.NewDecoder(bytes.NewReader(decoder.line))
+	jsonDecoder.UseNumber()
+
+	var m map[string]interface{}
+	err := jsonDecoder.Decode(&m)
+	if err != nil {
+		return errortools.ErrorMessagef("Row %v: %s", decoder.row, err.Error())
+	}
+
+	fieldErrors, e := MapToStruct(m, model, decoder.mapOptions)
+	if e != nil {
+		return e
+	}
+
+	if len(fieldErrors) == 0 {
+		return nil
+	}
+
+	for _, fieldError := range fieldErrors {
+		fieldError.Row = decoder.row
+	}
+	decoder.fieldErrors = append(decoder.fieldErrors, fieldErrors...)
+
+	if decoder.options.strict() {
+		return errortools.ErrorMessage(fieldErrors[0])
+	}
+
+	return nil
+}
+
+// Row returns the 1-based line number of the current line
+func (decoder *JsonLinesDecoder) Row() int {
+	return decoder.row
+}
+
+// FieldErrors returns the invalid values collected by Decode so far
+func (decoder *JsonLinesDecoder) FieldErrors() FieldErrors {
+	return decoder.fieldErrors
+}
+
+// Err returns the first read error encountered by Next, io.EOF excluded
+func (decoder *JsonLinesDecoder) Err() *errortools.Error {
+	return decoder.err
+}
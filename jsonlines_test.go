@@ -0,0 +1,98 @@
+package utilities
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type JsonLinesBase struct {
+	Id      int       `json:"id"`
+	Created time.Time `json:"created"`
+}
+
+type jsonLinesRow struct {
+	JsonLinesBase
+	Name   *string  `json:"name"`
+	Tags   []string `json:"tags"`
+	Amount float64  `json:"amount,omitempty"`
+}
+
+func decodeJsonLines(t *testing.T, data []byte, options *JsonLinesOptions) ([]jsonLinesRow, *JsonLinesDecoder) {
+	t.Helper()
+
+	decoder := NewJsonLinesDecoder(bytes.NewReader(data), options)
+
+	var rows []jsonLinesRow
+	for decoder.Next() {
+		var row jsonLinesRow
+		if e := decoder.Decode(&row); e != nil {
+			t.Fatal(e.Message())
+		}
+		rows = append(rows, row)
+	}
+	if e := decoder.Err(); e != nil {
+		t.Fatal(e.Message())
+	}
+
+	return rows, decoder
+}
+
+func TestJsonLinesRoundTrip(t *testing.T) {
+	name := "alice"
+	rows := []jsonLinesRow{
+		{JsonLinesBase: JsonLinesBase{Id: 1, Created: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}, Name: &name, Tags: []string{"a"}, Amount: 9.5},
+		{JsonLinesBase: JsonLinesBase{Id: 2, Created: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)}},
+	}
+
+	for _, options := range []*JsonLinesOptions{nil, {Gzip: true}} {
+		var b bytes.Buffer
+		encoder := NewJsonLinesEncoder(&b, options)
+		if e := encoder.EncodeAll(rows); e != nil {
+			t.Fatal(e.Message())
+		}
+		if e := encoder.Close(); e != nil {
+			t.Fatal(e.Message())
+		}
+
+		if !options.gzip() {
+			// embedded fields are written as encoding/json does
+			firstLine := strings.SplitN(b.String(), "\n", 2)[0]
+			if firstLine != `{"amount":9.5,"created":"2024-01-02T03:04:05Z","id":1,"name":"alice","tags":["a"]}` {
+				t.Fatalf("encoded '%s'", firstLine)
+			}
+		}
+
+		decoded, decoder := decodeJsonLines(t, b.Bytes(), nil)
+		if !reflect.DeepEqual(decoded, rows) {
+			t.Fatalf("decoded %+v, expected %+v", decoded, rows)
+		}
+		if len(decoder.FieldErrors()) > 0 {
+			t.Fatalf("got field errors %v", decoder.FieldErrors())
+		}
+	}
+}
+
+func TestJsonLinesDecoderFieldErrors(t *testing.T) {
+	data := []byte("{\"id\": 1}\n\n{\"id\": \"x\", \"name\": \"bob\"}\n")
+
+	rows, decoder := decodeJsonLines(t, data, nil)
+	if len(rows) != 2 || rows[0].Id != 1 || *rows[1].Name != "bob" {
+		t.Fatalf("decoded %+v", rows)
+	}
+
+	fieldErrors := decoder.FieldErrors()
+	if len(fieldErrors) != 1 || fieldErrors[0].Row != 3 || fieldErrors[0].Column != "id" {
+		t.Fatalf("got field errors %v", fieldErrors)
+	}
+
+	decoder = NewJsonLinesDecoder(bytes.NewReader(data), &JsonLinesOptions{Strict: true})
+	for decoder.Next() {
+		if e := decoder.Decode(&jsonLinesRow{}); e != nil {
+			return
+		}
+	}
+	t.Fatal("strict decoder accepted invalid value")
+}
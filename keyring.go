@@ -0,0 +1,226 @@
+package utilities
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync"
+)
+
+// KeyRing holds named AES keys, one of which is active. Values are encrypted as envelope
+// (see EncryptEnvelope) with the active key and decrypted with the key the envelope names,
+// so keys can be rotated without re-encrypting all values at once, see Rewrap.
+// Blobs without envelope, e.g. returned by Encrypt, are decrypted with the legacy key.
+type KeyRing struct {
+	cipherMode  CipherMode
+	mutex       sync.RWMutex
+	keys        map[string][]byte
+	activeKeyId string
+	legacyKeyId string
+}
+
+// NewKeyRing returns an empty KeyRing encrypting in cipherMode
+func NewKeyRing(cipherMode CipherMode) *KeyRing {
+	return &KeyRing{
+		cipherMode: cipherMode,
+		keys:       make(map[string][]byte),
+	}
+}
+
+// AddKey adds key, of 16, 24 or 32 bytes, as keyId, the first key added becoming the active key
+func (keyRing *KeyRing) AddKey(keyId string, key []byte) error {
+	if keyId == "" || len(keyId) > 255 {
+		return fmt.Errorf("Key ID should have 1 to 255 bytes")
+	}
+
+	_, err := cipherAlgorithm(key)
+	if err != nil {
+		return err
+	}
+
+	keyRing.mutex.Lock()
+	defer keyRing.mutex.Unlock()
+
+	if _, ok := keyRing.keys[keyId]; ok {
+		return fmt.Errorf("Key '%s' already exists", keyId)
+	}
+
+	keyRing.keys[keyId] = append([]byte{}, key...)
+	if keyRing.activeKeyId == "" {
+		keyRing.activeKeyId = keyId
+	}
+
+	return nil
+}
+
+// RemoveKey removes key keyId, which should not be the active key
+func (keyRing *KeyRing) RemoveKey(keyId string) error {
+	keyRing.mutex.Lock()
+	defer keyRing.mutex.Unlock()
+
+	if keyId == keyRing.activeKeyId {
+		return fmt.Errorf("Key '%s' is the active key", keyId)
+	}
+
+	delete(keyRing.keys, keyId)
+	if keyId == keyRing.legacyKeyId {
+		keyRing.legacyKeyId = ""
+	}
+
+	return nil
+}
+
+// SetActiveKey sets the key used for encryption
+func (keyRing *KeyRing) SetActiveKey(keyId string) error {
+	keyRing.mutex.Lock()
+	defer keyRing.mutex.Unlock()
+
+	if _, ok := keyRing.keys[keyId]; !ok {
+		return fmt.Errorf("Unknown key '%s'", keyId)
+	}
+
+	keyRing.activeKeyId = keyId
+
+	return nil
+}
+
+// ActiveKeyId returns the ID of the key used for encryption
+func (keyRing *KeyRing) ActiveKeyId() string {
+	keyRing.mutex.RLock()
+	defer keyRing.mutex.RUnlock()
+
+	return keyRing.activeKeyId
+}
+
+// SetLegacyKey sets the key used to decrypt blobs without envelope, e.g. LegacyKey(passphrase)
+// for values returned by Encrypt, in the cipher mode of the KeyRing
+func (keyRing *KeyRing) SetLegacyKey(keyId string) error {
+	keyRing.mutex.Lock()
+	defer keyRing.mutex.Unlock()
+
+	if _, ok := keyRing.keys[keyId]; !ok {
+		return fmt.Errorf("Unknown key '%s'", keyId)
+	}
+
+	keyRing.legacyKeyId = keyId
+
+	return nil
+}
+
+func (keyRing *KeyRing) key(keyId string) ([]byte, error) {
+	keyRing.mutex.RLock()
+	defer keyRing.mutex.RUnlock()
+
+	key, ok := keyRing.keys[keyId]
+	if !ok {
+		return nil, fmt.Errorf("Unknown key '%s'", keyId)
+	}
+
+	return key, nil
+}
+
+// Encrypt encrypts plainTextBytes with the active key
+func (keyRing *KeyRing) Encrypt(plainTextBytes []byte) (string, error) {
+	return keyRing.EncryptWithAad(plainTextBytes, nil)
+}
+
+// EncryptWithAad encrypts plainTextBytes with the active key, authenticating aad as additional data,
+// see EncryptEnvelopeWithAad
+func (keyRing *KeyRing) EncryptWithAad(plainTextBytes []byte, aad []byte) (string, error) {
+	keyId := keyRing.ActiveKeyId()
+	if keyId == "" {
+		return "", fmt.Errorf("Key ring has no keys")
+	}
+
+	key, err := keyRing.key(keyId)
+	if err != nil {
+		return "", err
+	}
+
+	return AesCrypto{CipherMode: keyRing.cipherMode}.EncryptEnvelopeWithAad(plainTextBytes, key, keyId, aad)
+}
+
+// Decrypt decrypts cipherText with the key named in its envelope or, lacking an envelope, the legacy key
+func (keyRing *KeyRing) Decrypt(cipherText string) (string, error) {
+	return keyRing.DecryptWithAad(cipherText, nil)
+}
+
+// DecryptWithAad decrypts like Decrypt, cipherText being encrypted with aad as additional data
+func (keyRing *KeyRing) DecryptWithAad(cipherText string, aad []byte) (string, error) {
+	plainText, _, err := keyRing.decrypt(cipherText, aad)
+
+	return plainText, err
+}
+
+// decrypt returns the plain text and the ID of the key cipherText was encrypted with
+func (keyRing *KeyRing) decrypt(cipherText string, aad []byte) (string, string, error) {
+	data, err := base64.StdEncoding.DecodeString(cipherText)
+	if err != nil {
+		return "", "", err
+	}
+
+	envelope, err := parseEnvelope(data)
+	if err != nil {
+		return keyRing.decryptLegacy(data, aad)
+	}
+
+	// envelopes are never decrypted with the legacy key, e.g. if their key has been removed
+	key, err := keyRing.key(envelope.KeyId)
+	if err != nil {
+		return "", "", err
+	}
+
+	plainTextBytes, err := envelope.decrypt(key, aad)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(plainTextBytes), envelope.KeyId, nil
+}
+
+func (keyRing *KeyRing) decryptLegacy(data []byte, aad []byte) (string, string, error) {
+	keyRing.mutex.RLock()
+	keyId := keyRing.legacyKeyId
+	keyRing.mutex.RUnlock()
+
+	if keyId == "" {
+		return "", "", fmt.Errorf("Data is not an envelope and key ring has no legacy key")
+	}
+
+	key, err := keyRing.key(keyId)
+	if err != nil {
+		return "", "", err
+	}
+
+	plainText, err := AesCrypto{CipherMode: keyRing.cipherMode}.decryptLegacy(data, key, "", aad)
+	if err != nil {
+		return "", "", err
+	}
+
+	// legacy blobs always need rewrapping
+	return plainText, "", nil
+}
+
+// Rewrap re-encrypts cipherText with the active key if it was encrypted with another key
+// or has no envelope, returning whether it did so
+func (keyRing *KeyRing) Rewrap(cipherText string) (string, bool, error) {
+	return keyRing.RewrapWithAad(cipherText, nil)
+}
+
+// RewrapWithAad rewraps like Rewrap, cipherText being encrypted with aad as additional data
+func (keyRing *KeyRing) RewrapWithAad(cipherText string, aad []byte) (string, bool, error) {
+	plainText, keyId, err := keyRing.decrypt(cipherText, aad)
+	if err != nil {
+		return "", false, err
+	}
+
+	if keyId == keyRing.ActiveKeyId() {
+		return cipherText, false, nil
+	}
+
+	rewrapped, err := keyRing.EncryptWithAad([]byte(plainText), aad)
+	if err != nil {
+		return "", false, err
+	}
+
+	return rewrapped, true, nil
+}
@@ -0,0 +1,129 @@
+package utilities
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestKeyRing(t *testing.T) {
+	keyRing := NewKeyRing(GCM)
+	if err := keyRing.AddKey("key-1", testKey(t, 32)); err != nil {
+		t.Fatal(err)
+	}
+
+	cipherText, err := keyRing.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = keyRing.AddKey("key-2", testKey(t, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if err = keyRing.SetActiveKey("key-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	rewrapped, ok, err := keyRing.Rewrap(cipherText)
+	if err != nil || !ok {
+		t.Fatalf("rewrapped %v, error %v", ok, err)
+	}
+	if envelope, _ := ParseEnvelope(rewrapped); envelope == nil || envelope.KeyId != "key-2" {
+		t.Fatal("envelope not rewrapped with active key")
+	}
+	if _, ok, _ = keyRing.Rewrap(rewrapped); ok {
+		t.Fatal("envelope with active key rewrapped")
+	}
+
+	// envelopes of a removed key are not decrypted with the legacy key
+	if err = keyRing.SetLegacyKey("key-2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = keyRing.RemoveKey("key-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = keyRing.Decrypt(cipherText); err == nil || err.Error() != "Unknown key 'key-1'" {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+
+	decrypted, err := keyRing.Decrypt(rewrapped)
+	if err != nil || decrypted != "secret" {
+		t.Fatalf("decrypted '%s', error %v", decrypted, err)
+	}
+}
+
+func TestKeyRingLegacy(t *testing.T) {
+	key := testKey(t, 32)
+
+	keyRing := NewKeyRing(GCM)
+	if err := keyRing.AddKey("legacy", key); err != nil {
+		t.Fatal(err)
+	}
+
+	cipherText, err := AesCrypto{CipherMode: GCM}.Encrypt([]byte("secret"), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = keyRing.Decrypt(cipherText); err == nil {
+		t.Fatal("legacy blob decrypted without legacy key")
+	}
+
+	if err = keyRing.SetLegacyKey("legacy"); err != nil {
+		t.Fatal(err)
+	}
+
+	rewrapped, ok, err := keyRing.Rewrap(cipherText)
+	if err != nil || !ok {
+		t.Fatalf("rewrapped %v, error %v", ok, err)
+	}
+	if !bytes.HasPrefix(mustDecodeBase64(t, rewrapped), envelopeMagic) {
+		t.Fatal("legacy blob not rewrapped as envelope")
+	}
+}
+
+func TestKeyRingAad(t *testing.T) {
+	keyRing := NewKeyRing(GCM)
+	if err := keyRing.AddKey("key-1", testKey(t, 32)); err != nil {
+		t.Fatal(err)
+	}
+
+	cipherText, err := keyRing.EncryptWithAad([]byte("secret"), []byte("tenant-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = keyRing.DecryptWithAad(cipherText, []byte("tenant-2")); err == nil {
+		t.Fatal("envelope decrypted with other aad")
+	}
+
+	if err = keyRing.AddKey("key-2", testKey(t, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if err = keyRing.SetActiveKey("key-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// rewrapping keeps the envelope bound to its aad
+	rewrapped, ok, err := keyRing.RewrapWithAad(cipherText, []byte("tenant-1"))
+	if err != nil || !ok {
+		t.Fatalf("rewrapped %v, error %v", ok, err)
+	}
+	if _, err = keyRing.Decrypt(rewrapped); err == nil {
+		t.Fatal("rewrapped envelope decrypted without aad")
+	}
+	decrypted, err := keyRing.DecryptWithAad(rewrapped, []byte("tenant-1"))
+	if err != nil || decrypted != "secret" {
+		t.Fatalf("decrypted '%s', error %v", decrypted, err)
+	}
+}
+
+func mustDecodeBase64(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
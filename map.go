@@ -1,5 +1,11 @@
 package utilities
 
+import (
+	"fmt"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"reflect"
+)
+
 func CopyMap(m map[string]interface{}) map[string]interface{} {
 	cp := make(map[string]interface{})
 	for k, v := range m {
@@ -13,3 +19,205 @@ func CopyMap(m map[string]interface{}) map[string]interface{} {
 
 	return cp
 }
+
+// MapOptions holds the options used when converting structs to and from maps.
+// Keys are named after Tag or, if Tag is nil, the field name. Fields of embedded structs
+// are promoted unless the embedded struct is named in its tag, as encoding/json does.
+// If FieldLayouts is set, StructToMap formats time.Time and civil values as strings.
+type MapOptions struct {
+	Tag          *string
+	FieldLayouts *FieldLayouts
+}
+
+func (options *MapOptions) structFields(t reflect.Type) []structField {
+	tagName := ""
+	if options.Tag != nil {
+		tagName = *options.Tag
+	}
+
+	return structFields(t, tagName, options.Tag == nil, promoteEmbedded)
+}
+
+// StructToMap converts model, a (pointer to a) struct, to a map. Nested structs become nested maps,
+// nil pointers, including embedded ones, and invalid bigquery.Null values nil and fields tagged
+// omitempty are left out if zero.
+func StructToMap(model interface{}, options *MapOptions) (map[string]interface{}, *errortools.Error) {
+	v := reflect.ValueOf(model)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return nil, errortools.ErrorMessage("The interface is not a (pointer to a) struct.")
+	}
+
+	if options == nil {
+		options = &MapOptions{}
+	}
+
+	m, err := options.structToMap(v)
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	return m, nil
+}
+
+func (options *MapOptions) structToMap(v reflect.Value) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+
+	for _, field := range options.structFields(v.Type()) {
+		f := fieldByIndex(v, field.index)
+		if !f.IsValid() {
+			// promoted from a nil embedded pointer
+			if !field.options.Contains("omitempty") {
+				m[field.name] = nil
+			}
+			continue
+		}
+
+		if field.options.Contains("omitempty") && f.IsZero() {
+			continue
+		}
+
+		value, err := options.mapValue(f)
+		if err != nil {
+			return nil, err
+		}
+
+		m[field.name] = value
+	}
+
+	return m, nil
+}
+
+func (options *MapOptions) mapValue(v reflect.Value) (interface{}, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	if v, _ = bigQueryNullValue(v); !v.IsValid() {
+		return nil, nil
+	}
+
+	if options.FieldLayouts != nil {
+		switch v.Type() {
+		case timeType, civilDateType, civilTimeType, civilDateTimeType:
+			return formatValue(v, options.FieldLayouts)
+		}
+	}
+
+	if isFlattenable(v.Type()) {
+		return options.structToMap(v)
+	}
+
+	if v.Kind() == reflect.Slice && v.Type() != bytesType && isFlattenable(v.Type().Elem()) {
+		if v.IsNil() {
+			return nil, nil
+		}
+
+		values := []interface{}{}
+		for i := 0; i < v.Len(); i++ {
+			value, err := options.mapValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	}
+
+	return v.Interface(), nil
+}
+
+// MapToStruct populates model, a pointer to a struct, from m, the inverse of StructToMap.
+// Values are converted to the type of their field, e.g. float64 to int as decoded from json
+// or strings to time.Time using FieldLayouts. Nested maps populate nested structs.
+// Nil pointers to embedded structs are allocated for the values of their promoted fields.
+// Values that cannot be converted are skipped and returned as FieldErrors.
+func MapToStruct(m map[string]interface{}, model interface{}, options *MapOptions) (FieldErrors, *errortools.Error) {
+	if reflect.TypeOf(model).Kind() != reflect.Ptr {
+		return nil, errortools.ErrorMessage("Model is not a pointer.")
+	}
+
+	v := reflect.ValueOf(model).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, errortools.ErrorMessage("Model is not a pointer to a struct.")
+	}
+
+	if options == nil {
+		options = &MapOptions{}
+	}
+
+	return options.mapToStruct(m, v, "", ""), nil
+}
+
+func (options *MapOptions) mapToStruct(m map[string]interface{}, v reflect.Value, namePrefix string, fieldNamePrefix string) FieldErrors {
+	var fieldErrors FieldErrors
+
+	for _, field := range options.structFields(v.Type()) {
+		value, ok := m[field.name]
+		if !ok {
+			continue
+		}
+
+		f := fieldByIndexAlloc(v, field.index)
+		if !f.IsValid() {
+			fieldErrors = append(fieldErrors, &FieldError{
+				Column: namePrefix + field.name,
+				Field:  fieldNamePrefix + field.fieldName,
+				Value:  fmt.Sprintf("%v", value),
+				Err:    errUnexportedEmbeddedPointer,
+			})
+			continue
+		}
+
+		_fieldErrors := options.setValue(f, value, namePrefix+field.name, fieldNamePrefix+field.fieldName)
+		fieldErrors = append(fieldErrors, _fieldErrors...)
+	}
+
+	return fieldErrors
+}
+
+func (options *MapOptions) setValue(f reflect.Value, value interface{}, name string, fieldName string) FieldErrors {
+	t := f.Type()
+
+	if nested, ok := value.(map[string]interface{}); ok && isFlattenable(t) {
+		if t.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				f.Set(reflect.New(t.Elem()))
+			}
+			f = f.Elem()
+		}
+
+		return options.mapToStruct(nested, f, name+defaultFlattenSeparator, fieldName+defaultFlattenSeparator)
+	}
+
+	if values, ok := value.([]interface{}); ok && t.Kind() == reflect.Slice && t != bytesType {
+		var fieldErrors FieldErrors
+
+		s := reflect.MakeSlice(t, len(values), len(values))
+		for i, element := range values {
+			fieldErrors = append(fieldErrors, options.setValue(s.Index(i), element, name, fieldName)...)
+		}
+		f.Set(s)
+
+		return fieldErrors
+	}
+
+	converted, err := convertValue(value, t, options.FieldLayouts)
+	if err != nil {
+		return FieldErrors{&FieldError{
+			Column: name,
+			Field:  fieldName,
+			Value:  fmt.Sprintf("%v", value),
+			Err:    err,
+		}}
+	}
+	f.Set(converted)
+
+	return nil
+}
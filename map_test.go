@@ -0,0 +1,198 @@
+package utilities
+
+import (
+	"bytes"
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type mapAddress struct {
+	City string `json:"city"`
+}
+
+type mapModel struct {
+	Id      int                `json:"id"`
+	Name    *string            `json:"name"`
+	Score   bigquery.NullInt64 `json:"score"`
+	Day     civil.Date         `json:"day"`
+	Tags    []string           `json:"tags"`
+	Address mapAddress         `json:"address"`
+	Homes   []mapAddress       `json:"homes"`
+	Note    string             `json:"note,omitempty"`
+}
+
+func TestStructToMap(t *testing.T) {
+	tag := "json"
+	model := mapModel{
+		Id:      1,
+		Score:   bigquery.NullInt64{Int64: 3, Valid: true},
+		Day:     civil.Date{Year: 2024, Month: 1, Day: 2},
+		Address: mapAddress{City: "Utrecht"},
+		Homes:   []mapAddress{{City: "Delft"}},
+	}
+
+	m, e := StructToMap(&model, &MapOptions{Tag: &tag})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	expected := map[string]interface{}{
+		"id":      1,
+		"name":    nil,
+		"score":   int64(3),
+		"day":     civil.Date{Year: 2024, Month: 1, Day: 2},
+		"tags":    []string(nil),
+		"address": map[string]interface{}{"city": "Utrecht"},
+		"homes":   []interface{}{map[string]interface{}{"city": "Delft"}},
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("got %v, expected %v", m, expected)
+	}
+
+	dateLayout := "2006-01-02"
+	m, e = StructToMap(&model, &MapOptions{Tag: &tag, FieldLayouts: &FieldLayouts{DateLayout: &dateLayout}})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	if m["day"] != "2024-01-02" {
+		t.Fatalf("got day %v", m["day"])
+	}
+}
+
+func TestMapJsonRoundTrip(t *testing.T) {
+	tag := "json"
+	dateLayout := "2006-01-02"
+	options := &MapOptions{Tag: &tag, FieldLayouts: &FieldLayouts{DateLayout: &dateLayout}}
+
+	name := "alice"
+	model := mapModel{
+		Id:      1,
+		Name:    &name,
+		Score:   bigquery.NullInt64{Int64: 3, Valid: true},
+		Day:     civil.Date{Year: 2024, Month: 1, Day: 2},
+		Tags:    []string{"a", "b"},
+		Address: mapAddress{City: "Utrecht"},
+		Homes:   []mapAddress{{City: "Delft"}},
+		Note:    "note",
+	}
+
+	m, e := StructToMap(&model, options)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	// through json, numbers becoming float64 and nested structs map[string]interface{}
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err = json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	var result mapModel
+	fieldErrors, e := MapToStruct(decoded, &result, options)
+	if e != nil || len(fieldErrors) > 0 {
+		t.Fatalf("error %v, field errors %v", e, fieldErrors)
+	}
+	if !reflect.DeepEqual(result, model) {
+		t.Fatalf("got %+v, expected %+v", result, model)
+	}
+}
+
+func TestMapToStructFieldErrors(t *testing.T) {
+	tag := "json"
+
+	decoder := json.NewDecoder(bytes.NewBufferString(`{"id": 1.5, "score": "x", "address": {"city": 3}}`))
+	decoder.UseNumber()
+	var m map[string]interface{}
+	if err := decoder.Decode(&m); err != nil {
+		t.Fatal(err)
+	}
+
+	var result mapModel
+	fieldErrors, e := MapToStruct(m, &result, &MapOptions{Tag: &tag})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	columns := map[string]bool{}
+	for _, fieldError := range fieldErrors {
+		columns[fieldError.Column] = true
+	}
+	if !reflect.DeepEqual(columns, map[string]bool{"id": true, "score": true}) {
+		t.Fatalf("got field errors %v", fieldErrors)
+	}
+	// numbers convert to strings
+	if result.Address.City != "3" {
+		t.Fatalf("got %+v", result)
+	}
+
+	if _, e = MapToStruct(m, result, nil); e == nil {
+		t.Fatal("accepted non pointer model")
+	}
+}
+
+func TestMapToStructTime(t *testing.T) {
+	model := struct {
+		When time.Time
+	}{}
+
+	fieldErrors, e := MapToStruct(map[string]interface{}{"When": "2024-01-02 03:04:05"}, &model, nil)
+	if e != nil || len(fieldErrors) > 0 {
+		t.Fatalf("error %v, field errors %v", e, fieldErrors)
+	}
+	if !model.When.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("got %v", model.When)
+	}
+}
+
+type MapBase struct {
+	Id      int    `json:"id"`
+	Created string `json:"created"`
+}
+
+type mapEmbedded struct {
+	MapBase
+	*mapAddress
+	Audit MapBase `json:"audit"`
+	Name  string  `json:"name"`
+}
+
+func TestMapEmbedded(t *testing.T) {
+	tag := "json"
+	options := &MapOptions{Tag: &tag}
+
+	model := mapEmbedded{MapBase: MapBase{Id: 1, Created: "today"}, Audit: MapBase{Id: 2}, Name: "alice"}
+
+	m, e := StructToMap(&model, options)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	// embedded fields are promoted, also from a nil pointer, tagged ones stay nested
+	expected := map[string]interface{}{
+		"id":      1,
+		"created": "today",
+		"city":    nil,
+		"audit":   map[string]interface{}{"id": 2, "created": ""},
+		"name":    "alice",
+	}
+	if !reflect.DeepEqual(m, expected) {
+		t.Fatalf("got %v, expected %v", m, expected)
+	}
+
+	var result mapEmbedded
+	fieldErrors, e := MapToStruct(map[string]interface{}{"id": 1, "city": "Utrecht"}, &result, options)
+	if e != nil || len(fieldErrors) != 1 || fieldErrors[0].Column != "city" {
+		t.Fatalf("error %v, field errors %v", e, fieldErrors)
+	}
+	if result.Id != 1 || result.mapAddress != nil {
+		t.Fatalf("got %+v", result)
+	}
+}
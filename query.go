@@ -0,0 +1,281 @@
+package utilities
+
+import (
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SliceFormat determines how slice fields are represented in a query string
+type SliceFormat int
+
+const (
+	// SliceFormatRepeat repeats the key for each element: key=a&key=b
+	SliceFormatRepeat SliceFormat = iota
+	// SliceFormatComma joins the elements by comma: key=a,b
+	SliceFormatComma
+)
+
+const (
+	defaultUrlTrueValue       string = "true"
+	defaultUrlFalseValue      string = "false"
+	defaultUrlTimestampLayout string = time.RFC3339
+	defaultUrlDateLayout      string = "2006-01-02"
+)
+
+// UrlOptions holds the options used when converting structs to and from query strings.
+// Fields are named after their Tag or, if Tag is nil, their field name.
+// Unset fields of FieldLayouts default to RFC 3339 for timestamps, 2006-01-02 for dates
+// and "true" and "false" for TrueValue and FalseValue.
+type UrlOptions struct {
+	Tag          *string
+	Flatten      *FlattenOptions
+	FieldLayouts *FieldLayouts
+	SliceFormat  SliceFormat
+}
+
+func (options *UrlOptions) fieldLayouts() *FieldLayouts {
+	var fieldLayouts FieldLayouts
+
+	if options.FieldLayouts != nil {
+		fieldLayouts = *options.FieldLayouts
+	}
+
+	if fieldLayouts.TimestampLayout == nil {
+		timestampLayout := defaultUrlTimestampLayout
+		fieldLayouts.TimestampLayout = &timestampLayout
+	}
+	if fieldLayouts.DateLayout == nil {
+		dateLayout := defaultUrlDateLayout
+		fieldLayouts.DateLayout = &dateLayout
+	}
+	if fieldLayouts.TrueValue == nil {
+		trueValue := defaultUrlTrueValue
+		fieldLayouts.TrueValue = &trueValue
+	}
+	if fieldLayouts.FalseValue == nil {
+		falseValue := defaultUrlFalseValue
+		fieldLayouts.FalseValue = &falseValue
+	}
+
+	return &fieldLayouts
+}
+
+func (options *UrlOptions) structFields(t reflect.Type) []structField {
+	tagName := ""
+	if options.Tag != nil {
+		tagName = *options.Tag
+	}
+
+	return structFields(t, tagName, options.Tag == nil, options.Flatten)
+}
+
+func StructToUrl(model interface{}, tag *string) (*string, *errortools.Error) {
+	return StructToUrlWithOptions(model, &UrlOptions{Tag: tag})
+}
+
+func StructToUrlWithOptions(model interface{}, options *UrlOptions) (*string, *errortools.Error) {
+	if IsNil(model) {
+		return nil, nil
+	}
+
+	values, e := StructToValues(model, options)
+	if e != nil {
+		return nil, e
+	}
+
+	url := values.Encode()
+
+	return &url, nil
+}
+
+// StructToValues converts model, a (pointer to a) struct, to url.Values.
+// Nil pointers are skipped, as are zero values of fields tagged with the omitempty option.
+func StructToValues(model interface{}, options *UrlOptions) (url.Values, *errortools.Error) {
+	values := url.Values{}
+
+	if IsNil(model) {
+		return values, nil
+	}
+
+	s := reflect.ValueOf(model)
+	for s.Kind() == reflect.Ptr || s.Kind() == reflect.Interface {
+		s = s.Elem()
+	}
+
+	if s.Kind() != reflect.Struct {
+		return nil, errortools.ErrorMessage("The interface is not a (pointer to a) struct.")
+	}
+
+	if options == nil {
+		options = &UrlOptions{}
+	}
+
+	fieldLayouts := options.fieldLayouts()
+
+	for _, structField := range options.structFields(s.Type()) {
+		fieldName := structField.name
+
+		field := fieldByIndex(s, structField.index)
+		if !field.IsValid() {
+			continue
+		}
+
+		if field.Kind() == reflect.Ptr {
+			if field.IsNil() {
+				continue
+			}
+
+			field = field.Elem()
+		}
+
+		if structField.options.Contains("omitempty") && field.IsZero() {
+			continue
+		}
+
+		if field.Kind() == reflect.Slice && registry.formatter(field.Type()) == nil {
+			elements := []string{}
+			for i := 0; i < field.Len(); i++ {
+				element, err := formatValue(field.Index(i), fieldLayouts)
+				if err != nil {
+					return nil, errortools.ErrorMessagef("Field '%s': %s", fieldName, err.Error())
+				}
+				elements = append(elements, element)
+			}
+
+			if len(elements) == 0 {
+				continue
+			}
+
+			if options.SliceFormat == SliceFormatComma {
+				values.Set(fieldName, strings.Join(elements, ","))
+			} else {
+				values[fieldName] = elements
+			}
+
+			continue
+		}
+
+		var value string
+		var err error
+		if structField.basicFormat {
+			value = formatBasic(field, fieldLayouts)
+		} else {
+			value, err = formatValue(field, fieldLayouts)
+		}
+		if err != nil {
+			return nil, errortools.ErrorMessagef("Field '%s': %s", fieldName, err.Error())
+		}
+
+		values.Set(fieldName, value)
+	}
+
+	return values, nil
+}
+
+// UrlToStruct populates model, a pointer to a struct, from query, a raw query string
+// optionally prefixed by "?", see ValuesToStruct
+func UrlToStruct(query string, model interface{}, options *UrlOptions) (FieldErrors, *errortools.Error) {
+	values, err := url.ParseQuery(strings.TrimPrefix(query, "?"))
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	return ValuesToStruct(values, model, options)
+}
+
+// ValuesToStruct populates model, a pointer to a struct, from values, the inverse of StructToValues.
+// Slice fields are filled from repeated keys or, with SliceFormatComma, comma separated values.
+// Empty values set pointer fields to nil and leave other fields untouched.
+// Values that cannot be assigned to their field are skipped and returned as FieldErrors.
+func ValuesToStruct(values url.Values, model interface{}, options *UrlOptions) (FieldErrors, *errortools.Error) {
+	if reflect.TypeOf(model).Kind() != reflect.Ptr {
+		return nil, errortools.ErrorMessage("Model is not a pointer.")
+	}
+
+	s := reflect.ValueOf(model).Elem()
+	if s.Kind() != reflect.Struct {
+		return nil, errortools.ErrorMessage("Model is not a pointer to a struct.")
+	}
+
+	if options == nil {
+		options = &UrlOptions{}
+	}
+
+	fieldLayouts := options.fieldLayouts()
+
+	var fieldErrors FieldErrors
+
+	for _, structField := range options.structFields(s.Type()) {
+		_values, ok := values[structField.name]
+		if !ok || len(_values) == 0 {
+			continue
+		}
+
+		if strings.Join(_values, "") == "" {
+			// as in csv, empty values set pointers to nil and leave other fields untouched
+			if f := fieldByIndex(s, structField.index); f.IsValid() && f.Kind() == reflect.Ptr {
+				f.Set(reflect.Zero(f.Type()))
+			}
+			continue
+		}
+
+		var f reflect.Value
+
+		v, err := options.parse(_values, structField.field.Type, fieldLayouts)
+		if err == nil {
+			if f = fieldByIndexAlloc(s, structField.index); !f.IsValid() {
+				err = errUnexportedEmbeddedPointer
+			}
+		}
+		if err != nil {
+			fieldErrors = append(fieldErrors, &FieldError{
+				Column: structField.name,
+				Field:  structField.fieldName,
+				Value:  strings.Join(_values, ","),
+				Err:    err,
+			})
+			continue
+		}
+
+		f.Set(v)
+	}
+
+	return fieldErrors, nil
+}
+
+func (options *UrlOptions) parse(values []string, t reflect.Type, fieldLayouts *FieldLayouts) (reflect.Value, error) {
+	if t.Kind() == reflect.Ptr {
+		v, err := options.parse(values, t.Elem(), fieldLayouts)
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(v)
+		return p, nil
+	}
+
+	if t.Kind() == reflect.Slice && registry.parser(t) == nil {
+		elements := values
+		if options.SliceFormat == SliceFormatComma {
+			elements = []string{}
+			for _, value := range values {
+				elements = append(elements, strings.Split(value, ",")...)
+			}
+		}
+
+		v := reflect.MakeSlice(t, 0, len(elements))
+		for _, element := range elements {
+			e, err := options.parse([]string{element}, t.Elem(), fieldLayouts)
+			if err != nil {
+				return v, err
+			}
+			v = reflect.Append(v, e)
+		}
+		return v, nil
+	}
+
+	return parseString(values[0], t, fieldLayouts)
+}
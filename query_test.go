@@ -0,0 +1,177 @@
+package utilities
+
+import (
+	"cloud.google.com/go/civil"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type queryModel struct {
+	Page     int        `json:"page"`
+	Query    string     `json:"q,omitempty"`
+	Tags     []string   `json:"tags"`
+	Active   bool       `json:"active"`
+	Limit    *int       `json:"limit"`
+	Since    time.Time  `json:"since"`
+	Day      civil.Date `json:"day,omitempty"`
+	Internal string     `json:"-"`
+}
+
+func TestStructToValues(t *testing.T) {
+	tag := "json"
+	model := queryModel{
+		Page:   2,
+		Tags:   []string{"a", "b"},
+		Active: true,
+		Since:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Day:    civil.Date{Year: 2024, Month: 1, Day: 2},
+	}
+
+	values, e := StructToValues(&model, &UrlOptions{Tag: &tag})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	expected := url.Values{
+		"page":   {"2"},
+		"tags":   {"a", "b"},
+		"active": {"true"},
+		"since":  {"2024-01-02T03:04:05Z"},
+		"day":    {"2024-01-02"},
+	}
+	if values.Encode() != expected.Encode() {
+		t.Fatalf("got %s, expected %s", values.Encode(), expected.Encode())
+	}
+
+	values, e = StructToValues(&model, &UrlOptions{Tag: &tag, SliceFormat: SliceFormatComma})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	if values.Get("tags") != "a,b" {
+		t.Fatalf("got tags %v", values["tags"])
+	}
+}
+
+func TestStructToValuesFieldLayoutDefaults(t *testing.T) {
+	tag := "json"
+	model := queryModel{
+		Since: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Day:   civil.Date{Year: 2024, Month: 1, Day: 2},
+	}
+
+	// setting some FieldLayouts keeps the defaults of the others
+	trueValue := "1"
+	options := &UrlOptions{Tag: &tag, FieldLayouts: &FieldLayouts{Location: time.FixedZone("CET", 3600), TrueValue: &trueValue}}
+
+	values, e := StructToValues(&model, options)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	if values.Get("since") != "2024-01-02T04:04:05+01:00" || values.Get("day") != "2024-01-02" || values.Get("active") != "false" {
+		t.Fatalf("got %s", values.Encode())
+	}
+}
+
+func TestStructToUrl(t *testing.T) {
+	model := struct {
+		Id   int
+		Name string
+	}{Id: 1, Name: "a b"}
+
+	query, e := StructToUrl(&model, nil)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	if *query != "Id=1&Name=a+b" {
+		t.Fatalf("got '%s'", *query)
+	}
+
+	if query, e = StructToUrl(nil, nil); e != nil || query != nil {
+		t.Fatalf("got %v, %v for nil model", query, e)
+	}
+}
+
+func TestUrlToStruct(t *testing.T) {
+	tag := "json"
+
+	var model queryModel
+	fieldErrors, e := UrlToStruct("?page=2&q=go&tags=a&tags=b&active=true&limit=10&since=2024-01-02T03:04:05Z&day=2024-01-02&Internal=x", &model, &UrlOptions{Tag: &tag})
+	if e != nil || len(fieldErrors) > 0 {
+		t.Fatalf("error %v, field errors %v", e, fieldErrors)
+	}
+
+	if model.Page != 2 || model.Query != "go" || len(model.Tags) != 2 || !model.Active || model.Limit == nil || *model.Limit != 10 ||
+		!model.Since.Equal(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)) || model.Day != (civil.Date{Year: 2024, Month: 1, Day: 2}) || model.Internal != "" {
+		t.Fatalf("got %+v", model)
+	}
+
+	fieldErrors, e = UrlToStruct("tags=c,d&page=x", &model, &UrlOptions{Tag: &tag, SliceFormat: SliceFormatComma})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	if len(model.Tags) != 2 || model.Tags[0] != "c" {
+		t.Fatalf("got tags %v", model.Tags)
+	}
+	if len(fieldErrors) != 1 || fieldErrors[0].Column != "page" || model.Page != 2 {
+		t.Fatalf("got field errors %v", fieldErrors)
+	}
+}
+
+func TestUrlToStructEmptyValues(t *testing.T) {
+	tag := "json"
+
+	limit := 10
+	model := queryModel{Page: 2, Limit: &limit, Tags: []string{"a"}}
+
+	fieldErrors, e := UrlToStruct("?page=&limit=&tags=&since=", &model, &UrlOptions{Tag: &tag})
+	if e != nil || len(fieldErrors) > 0 {
+		t.Fatalf("error %v, field errors %v", e, fieldErrors)
+	}
+
+	// empty values set pointers to nil and leave other fields untouched
+	if model.Page != 2 || model.Limit != nil || len(model.Tags) != 1 || !model.Since.IsZero() {
+		t.Fatalf("got %+v", model)
+	}
+}
+
+func TestQueryRoundTrip(t *testing.T) {
+	tag := "json"
+	limit := 0
+
+	model := queryModel{
+		Page:   3,
+		Query:  "a&b=c",
+		Tags:   []string{"x y", "z"},
+		Active: true,
+		Limit:  &limit,
+		Since:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600)),
+		Day:    civil.Date{Year: 2024, Month: 2, Day: 29},
+	}
+
+	for _, options := range []*UrlOptions{
+		{Tag: &tag},
+		{Tag: &tag, SliceFormat: SliceFormatComma, FieldLayouts: &FieldLayouts{Location: time.UTC}},
+	} {
+		query, e := StructToUrlWithOptions(&model, options)
+		if e != nil {
+			t.Fatal(e.Message())
+		}
+
+		var decoded queryModel
+		fieldErrors, e := UrlToStruct(*query, &decoded, options)
+		if e != nil || len(fieldErrors) > 0 {
+			t.Fatalf("error %v, field errors %v", e, fieldErrors)
+		}
+
+		if !decoded.Since.Equal(model.Since) {
+			t.Fatalf("decoded time %v, expected %v", decoded.Since, model.Since)
+		}
+		decoded.Since = model.Since
+
+		if decoded.Page != model.Page || decoded.Query != model.Query || decoded.Tags[0] != "x y" || len(decoded.Tags) != 2 ||
+			!decoded.Active || decoded.Limit == nil || *decoded.Limit != 0 || decoded.Day != model.Day {
+			t.Fatalf("decoded %+v from '%s'", decoded, *query)
+		}
+	}
+}
@@ -2,13 +2,8 @@ package utilities
 
 import (
 	"cloud.google.com/go/bigquery"
-	"cloud.google.com/go/civil"
-	"encoding/json"
-	"fmt"
 	errortools "github.com/leapforce-libraries/go_errortools"
-	"net/url"
 	"reflect"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -16,132 +11,131 @@ import (
 // GetTaggedFieldNames returns comma separated string of
 // fieldnames of struct having a specified tag
 func GetTaggedFieldNames(tag string, model interface{}) string {
-	return getTaggedNames(tag, model, "field")
+	return getTaggedNames(tag, model, "field", nil)
 }
 
 // GetTaggedTagNames returns comma separated string of
 // fieldnames of struct having a specified tag
 func GetTaggedTagNames(tag string, model interface{}) string {
-	return getTaggedNames(tag, model, "tag")
+	return getTaggedNames(tag, model, "tag", nil)
 }
 
-func getTaggedNames(tag string, model interface{}, fieldOrTag string) string {
-	val := reflect.ValueOf(model)
-	list := ""
-	for i := 0; i < val.Type().NumField(); i++ {
-		field := val.Type().Field(i)
-		value, ok := field.Tag.Lookup(tag)
-		if ok {
-			if fieldOrTag == "field" {
-				list += "," + field.Name
-			} else if fieldOrTag == "tag" {
-				i := strings.Index(value, ",")
-				if i >= 0 {
-					value = value[:i]
-				}
-
-				list += "," + value
-			}
-		}
+// GetTaggedFieldNamesFlattened returns comma separated string of
+// fieldnames of struct having a specified tag, including those of embedded and nested structs
+func GetTaggedFieldNamesFlattened(tag string, model interface{}, flatten *FlattenOptions) string {
+	return getTaggedNames(tag, model, "field", flattenOrDefault(flatten))
+}
+
+// GetTaggedTagNamesFlattened returns comma separated string of
+// tag names of struct, including those of embedded and nested structs
+func GetTaggedTagNamesFlattened(tag string, model interface{}, flatten *FlattenOptions) string {
+	return getTaggedNames(tag, model, "tag", flattenOrDefault(flatten))
+}
+
+func getTaggedNames(tag string, model interface{}, fieldOrTag string, flatten *FlattenOptions) string {
+	t := reflect.TypeOf(model)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
 
-	list = strings.Trim(list, ",")
+	names := []string{}
+	for _, field := range structFields(t, tag, false, flatten) {
+		if fieldOrTag == "field" {
+			names = append(names, field.fieldName)
+		} else if fieldOrTag == "tag" {
+			names = append(names, field.name)
+		}
+	}
 
-	return list
+	return strings.Join(names, ",")
 }
 
+// StringArrayToStruct appends the records, the first of which being the header row,
+// as structs to model, a pointer to a slice. Values that cannot be assigned are skipped,
+// use StringArrayToStructWithOptions to retrieve them.
 func StringArrayToStruct(records *[][]string, model interface{}) *errortools.Error {
+	_, e := StringArrayToStructWithOptions(records, model, nil)
+	return e
+}
+
+// StringArrayToStructWithOptions appends the records, the first of which being the header row
+// unless the SkipRows or NoHeader options are set, as structs to model, a pointer to a slice.
+// It returns all values that could not be assigned to their field, or, in strict mode,
+// an error for the first one.
+func StringArrayToStructWithOptions(records *[][]string, model interface{}, options *CsvOptions) (FieldErrors, *errortools.Error) {
 	if records == nil {
-		return nil
+		return nil, nil
 	}
 
 	if reflect.TypeOf(model).Kind() != reflect.Ptr {
-		return errortools.ErrorMessage("The interface is not a pointer.")
+		return nil, errortools.ErrorMessage("The interface is not a pointer.")
 	}
 
 	v := reflect.ValueOf(model).Elem()
 	if v.Kind() != reflect.Slice {
-		return errortools.ErrorMessage("The interface is not a pointer to a slice.")
+		return nil, errortools.ErrorMessage("The interface is not a pointer to a slice.")
 	}
 
 	rv := reflect.ValueOf(model)
 
 	structType := reflect.TypeOf(model).Elem().Elem()
 
-	numFields := structType.NumField()
+	skipRows, skipTrailingRows := options.skipRows()
 
-	fields := make(map[string]int)
+	var mapping *csvMapping
+	var fieldErrors FieldErrors
 
 	for index, record := range *records {
 		for j, v := range record {
-			//remove inivisible characters and trim
-			v = strings.ReplaceAll(v, string([]byte{byte(239)}), "")
-			v = strings.ReplaceAll(v, string([]byte{byte(187)}), "")
-			v = strings.ReplaceAll(v, string([]byte{byte(191)}), "")
-			v = strings.Trim(v, " ")
-
-			(*records)[index][j] = v
-		}
-
-		if index == 0 {
-			for cellIndex, cellValue := range record {
-				fields[strings.Trim(cellValue, " ")] = cellIndex
+			if index == 0 && j == 0 {
+				v = trimBom(v)
 			}
+			(*records)[index][j] = cleanCsvCell(v)
+		}
 
+		if index < skipRows || index >= len(*records)-skipTrailingRows {
 			continue
 		}
 
-		new := reflect.New(structType).Elem()
+		if mapping == nil {
+			var header []string
+			if !options.noHeader() {
+				header = record
+			}
 
-		for i := 0; i < numFields; i++ {
-			fieldName := structType.Field(i).Name
-			fieldTag := structType.Field(i).Tag.Get("csv")
+			_mapping, e := newCsvMapping(structType, header, options)
+			if e != nil {
+				return nil, e
+			}
+			mapping = _mapping
 
-			if fieldTag == "" {
+			if header != nil {
 				continue
 			}
-			fieldIndex, ok := fields[fieldTag]
-
-			if ok {
-				value := strings.Trim(record[fieldIndex], " ")
-
-				switch new.FieldByName(fieldName).Kind() {
-				case reflect.String:
-					new.FieldByName(fieldName).SetString(value)
-				case reflect.Int:
-				case reflect.Int32:
-				case reflect.Int64:
-					i, err := strconv.ParseInt(value, 10, 64)
-					if err == nil {
-						new.FieldByName(fieldName).SetInt(i)
-					}
-				case reflect.Float64:
-					i, err := strconv.ParseFloat(value, 64)
-					if err == nil {
-						new.FieldByName(fieldName).SetFloat(i)
-					}
-				default:
-					// create pointer to new instance of type of field
-					t := reflect.New(structType.Field(i).Type).Interface()
-					// unmarshal to this type with input the json representation of the string value
-					err := json.Unmarshal([]byte(fmt.Sprintf("\"%s\"", value)), t)
-					if err == nil {
-						new.FieldByName(fieldName).Set(reflect.ValueOf(t).Elem())
-					} else {
-						fmt.Println(err)
-					}
-				}
+		}
 
-			}
+		new := reflect.New(structType).Elem()
+
+		_fieldErrors := mapping.decode(record, new, index+1, options)
+		fieldErrors = append(fieldErrors, _fieldErrors...)
+
+		if len(_fieldErrors) > 0 && options.strict() {
+			return fieldErrors, errortools.ErrorMessage(_fieldErrors[0])
 		}
 
 		rv.Elem().Set(reflect.Append(rv.Elem(), new))
 	}
 
-	return nil
+	return fieldErrors, nil
 }
 
 func StructToStringArray(model interface{}, includeHeaders bool) (*[][]string, *errortools.Error) {
+	return StructToStringArrayWithOptions(model, includeHeaders, nil)
+}
+
+// StructToStringArrayWithOptions converts model, a pointer to a slice of structs, to csv records,
+// using the FieldLayouts and Flatten options
+func StructToStringArrayWithOptions(model interface{}, includeHeaders bool, options *CsvOptions) (*[][]string, *errortools.Error) {
 	if reflect.TypeOf(model).Kind() != reflect.Ptr {
 		return nil, errortools.ErrorMessage("The interface is not a pointer.")
 	}
@@ -155,14 +149,17 @@ func StructToStringArray(model interface{}, includeHeaders bool) (*[][]string, *
 
 	records := [][]string{}
 
+	columns, _, err := csvFields(structType, true, options.flatten())
+	if err != nil {
+		return nil, errortools.ErrorMessage(err)
+	}
+
+	positions, width := csvPositions(columns)
+
 	if includeHeaders {
-		record := []string{}
-		for i := 0; i < structType.NumField(); i++ {
-			fieldName := structType.Field(i).Tag.Get("csv")
-			if fieldName == "" {
-				fieldName = structType.Field(i).Name
-			}
-			record = append(record, fieldName)
+		record := make([]string, width)
+		for i, column := range columns {
+			record[positions[i]] = column.header()
 		}
 
 		records = append(records, record)
@@ -170,10 +167,15 @@ func StructToStringArray(model interface{}, includeHeaders bool) (*[][]string, *
 
 	for i := 0; i < v.Len(); i++ {
 
-		record := []string{}
+		record := make([]string, width)
 		v1 := v.Index(i)
-		for j := 0; j < v1.NumField(); j++ {
-			record = append(record, GetStructFieldStringByFieldIndex(v1.Addr().Interface(), j))
+		for j, column := range columns {
+			value := ""
+			f := fieldByIndex(v1, column.index)
+			if f.IsValid() && !f.IsZero() {
+				value = column.formatString(f, options.fieldLayouts())
+			}
+			record[positions[j]] = value
 		}
 
 		records = append(records, record)
@@ -182,66 +184,6 @@ func StructToStringArray(model interface{}, includeHeaders bool) (*[][]string, *
 	return &records, nil
 }
 
-func StructToUrl(model interface{}, tag *string) (*string, *errortools.Error) {
-	if IsNil(model) {
-		return nil, nil
-	}
-
-	if reflect.TypeOf(model).Kind() != reflect.Ptr {
-		return nil, errortools.ErrorMessage("The interface is not a pointer.")
-	}
-
-	p := reflect.ValueOf(model) //pointer
-	s := p.Elem()               //interface
-
-	if s.Kind() != reflect.Struct {
-		s = s.Elem()
-	}
-
-	if s.Kind() != reflect.Struct {
-		return nil, errortools.ErrorMessage("The interface is not a pointer to a struct.")
-	}
-
-	values := url.Values{}
-
-	for j := 0; j < s.NumField(); j++ {
-		fieldName := s.Type().Field(j).Name
-
-		if tag != nil {
-			tagValue := s.Type().Field(j).Tag.Get(*tag)
-
-			if tagValue == "" {
-				continue
-			}
-			fieldName = tagValue
-		}
-
-		field := s.Field(j)
-
-		if field.Kind() == reflect.Ptr {
-			if field.IsNil() {
-				continue
-			}
-
-			field = field.Elem()
-		}
-
-		switch field.Kind() {
-		case reflect.String:
-			values.Set(fieldName, field.String())
-		case reflect.Int:
-			values.Set(fieldName, strconv.FormatInt(field.Int(), 10))
-		case reflect.Float64:
-			values.Set(fieldName, strconv.FormatFloat(field.Float(), 'f', 5, 64))
-		default:
-		}
-	}
-
-	url := values.Encode()
-
-	return &url, nil
-}
-
 func SetStructField(model interface{}, fieldName string, value interface{}) *errortools.Error {
 	if reflect.TypeOf(model).Kind() != reflect.Ptr {
 		return errortools.ErrorMessage("Model is not a pointer.")
@@ -260,7 +202,11 @@ func SetStructField(model interface{}, fieldName string, value interface{}) *err
 
 	if f.IsValid() {
 		if f.CanSet() {
-			f.Set(reflect.ValueOf(value))
+			v, err := convertValue(value, f.Type(), nil)
+			if err != nil {
+				return errortools.ErrorMessagef("Field '%s': %s", fieldName, err.Error())
+			}
+			f.Set(v)
 		}
 	}
 
@@ -272,6 +218,16 @@ func SetStructFieldByTag(model interface{}, tagName string, tag string, value in
 }
 
 func SetStructFieldByTagWithFieldLayouts(model interface{}, tagName string, tag string, value interface{}, fieldLayouts *FieldLayouts) *errortools.Error {
+	return setStructFieldByTag(model, tagName, tag, value, fieldLayouts, nil)
+}
+
+// SetStructFieldByTagFlattened sets the field of model having tag, which for fields of
+// nested structs is the path of tags joined by the separator of flatten, e.g. `address.city`
+func SetStructFieldByTagFlattened(model interface{}, tagName string, tag string, value interface{}, fieldLayouts *FieldLayouts, flatten *FlattenOptions) *errortools.Error {
+	return setStructFieldByTag(model, tagName, tag, value, fieldLayouts, flattenOrDefault(flatten))
+}
+
+func setStructFieldByTag(model interface{}, tagName string, tag string, value interface{}, fieldLayouts *FieldLayouts, flatten *FlattenOptions) *errortools.Error {
 	if reflect.TypeOf(model).Kind() != reflect.Ptr {
 		return errortools.ErrorMessage("Model is not a pointer.")
 	}
@@ -283,54 +239,25 @@ func SetStructFieldByTagWithFieldLayouts(model interface{}, tagName string, tag
 		return errortools.ErrorMessage("Model is not a pointer to a struct.")
 	}
 
-	for i := 0; i < s.Type().NumField(); i++ {
-		// 1 create sqlSelect
-		// 2 register customfields: json tag -> fieldname
-		field := s.Type().Field(i)
-		_tag, ok := field.Tag.Lookup(tagName)
-
-		if !ok {
-			continue
-		}
-
-		index := strings.Index(_tag, ",")
-		if index >= 0 {
-			_tag = _tag[:index]
-		}
+	field, ok := cachedStructFields(s.Type(), tagName, false, flatten).field(tag)
+	if !ok {
+		return nil
+	}
 
-		if _tag == tag {
-			f := s.FieldByName(field.Name)
-
-			if f.IsValid() {
-				if f.CanSet() {
-					if fieldLayouts != nil {
-						fieldValue := f.Interface()
-						switch fieldValue.(type) {
-						case civil.Date:
-							if fieldLayouts.DateLayout != nil {
-								t, err := time.Parse(*fieldLayouts.DateLayout, fmt.Sprintf("%v", value))
-								if err != nil {
-									return errortools.ErrorMessage(err)
-								}
-								value = civil.DateOf(t)
-							}
-						case time.Time:
-							if fieldLayouts.TimeLayout != nil {
-								t, err := time.Parse(*fieldLayouts.TimeLayout, fmt.Sprintf("%v", value))
-								if err != nil {
-									return errortools.ErrorMessage(err)
-								}
-								value = t
-							}
-						}
-					}
-					f.Set(reflect.ValueOf(value))
-					return nil
-				}
-			}
-		}
+	f := fieldByIndexAlloc(s, field.index)
+	if !f.IsValid() {
+		return errortools.ErrorMessagef("Field '%s': %s", tag, errUnexportedEmbeddedPointer.Error())
+	}
+	if !f.CanSet() {
+		return nil
+	}
 
+	v, err := field.convert(value, fieldLayouts)
+	if err != nil {
+		return errortools.ErrorMessagef("Field '%s': %s", tag, err.Error())
 	}
+	f.Set(v)
+
 	return nil
 }
 
@@ -353,13 +280,28 @@ func GetStructFieldStringByFieldIndexWithLayouts(model interface{}, index int, f
 const (
 	defaultTimestampLayout string = "2006-01-02 15:04:05"
 	defaultDateLayout      string = "02-01-2006"
-	defaultTimeLayout      string = "02-01-2006"
+	defaultTimeLayout      string = "15:04:05"
 )
 
+// FieldLayouts holds the layouts used when formatting and parsing values as strings.
+//
+// TimestampLayout applies to time.Time and civil.DateTime, DateLayout to civil.Date and
+// TimeLayout to civil.Time. For backward compatibility time.Time is parsed using TimeLayout
+// if TimestampLayout is not set. Location is the time zone time.Time values are formatted in
+// and parsed in if the value holds no zone itself.
+// DecimalSeparator and ThousandsSeparator apply to numbers, Precision to the number of
+// decimals of floats, -1 meaning as many as needed. TrueValue and FalseValue replace
+// "TRUE" and "FALSE" when formatting, parsing accepts them besides the default notations.
 type FieldLayouts struct {
-	TimestampLayout *string
-	DateLayout      *string
-	TimeLayout      *string
+	TimestampLayout    *string
+	DateLayout         *string
+	TimeLayout         *string
+	Location           *time.Location
+	DecimalSeparator   *string
+	ThousandsSeparator *string
+	Precision          *int
+	TrueValue          *string
+	FalseValue         *string
 }
 
 func GetStructFieldStringByFieldName(model interface{}, fieldName string) string {
@@ -378,84 +320,19 @@ func GetStructFieldStringByFieldNameWithLayouts(model interface{}, fieldName str
 	return getStructFieldString(f, fieldLayouts)
 }
 
+// getStructFieldString formats field f using the registered value formatters,
+// falling back to the built-in formatting, and returns "" if it cannot be formatted
 func getStructFieldString(f reflect.Value, fieldLayouts *FieldLayouts) string {
-	timestampLayout := func() string {
-		if fieldLayouts != nil {
-			if fieldLayouts.TimestampLayout != nil {
-				return *fieldLayouts.TimestampLayout
-			}
-		}
-
-		return defaultTimestampLayout
-	}
-
-	dateLayout := func() string {
-		if fieldLayouts != nil {
-			if fieldLayouts.DateLayout != nil {
-				return *fieldLayouts.DateLayout
-			}
+	if v, ok := f.Interface().(bigquery.NullDate); ok {
+		// 1800-01-01 is used as empty date
+		if v.Valid && v.Date.Day == 1 && v.Date.Month == 1 && v.Date.Year == 1800 {
+			return ""
 		}
-
-		return defaultDateLayout
 	}
 
-	fieldValue := f.Interface()
-	value := ""
-	switch v := fieldValue.(type) {
-
-	case bigquery.NullFloat64:
-		if v.Valid {
-			value = strconv.FormatFloat(v.Float64, 'f', -1, 64)
-		} else {
-			value = ""
-		}
-	case bigquery.NullInt64:
-		if v.Valid {
-			value = strconv.FormatInt(v.Int64, 10)
-		} else {
-			value = ""
-		}
-	case float64:
-		value = strconv.FormatFloat(v, 'f', -1, 64)
-	case int64:
-		value = strconv.FormatInt(v, 10)
-	case int32:
-		value = strconv.FormatInt(int64(v), 10)
-	case int:
-		value = strconv.FormatInt(int64(v), 10)
-	case string:
-		value = v
-	case bool:
-		if v {
-			value = "TRUE"
-		}
-		value = "FALSE"
-	case bigquery.NullTimestamp:
-		if v.Valid {
-			value = v.Timestamp.Format(timestampLayout())
-		} else {
-			value = ""
-		}
-	case bigquery.NullDate:
-		if v.Valid {
-			if v.Date.Day == 1 && v.Date.Month == 1 && v.Date.Year == 1800 {
-				value = ""
-			} else {
-				time, _ := time.Parse("02-01-2006", fmt.Sprintf("%02d-%02d-%04d", v.Date.Day, v.Date.Month, v.Date.Year))
-				value = time.Format(dateLayout())
-			}
-		} else {
-			value = ""
-		}
-	case time.Time:
-		value = v.Format(timestampLayout())
-	case bigquery.NullString:
-		value = ""
-		if v.Valid {
-			value = v.StringVal
-		}
-	default:
-		value = ""
+	value, err := formatValue(f, fieldLayouts)
+	if err != nil {
+		return ""
 	}
 
 	return value
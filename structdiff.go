@@ -0,0 +1,153 @@
+package utilities
+
+import (
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// FieldChange describes a field that differs between two values of the same struct type.
+// Name is the tag name, for fields of nested structs the path of tag names, e.g. `address.city`.
+// Nil pointers and invalid bigquery.Null values are represented by nil.
+type FieldChange struct {
+	Name     string
+	Field    string
+	OldValue interface{}
+	NewValue interface{}
+}
+
+// StructDiff compares oldModel and newModel, (pointers to) structs of the same type,
+// field by field and returns the changes. Only fields having tag tagName are compared,
+// nested structs are compared field by field.
+func StructDiff(oldModel interface{}, newModel interface{}, tagName string) ([]FieldChange, *errortools.Error) {
+	oldValue := reflect.ValueOf(oldModel)
+	newValue := reflect.ValueOf(newModel)
+
+	if oldValue.Kind() == reflect.Ptr {
+		oldValue = oldValue.Elem()
+	}
+	if newValue.Kind() == reflect.Ptr {
+		newValue = newValue.Elem()
+	}
+
+	if oldValue.Kind() != reflect.Struct || newValue.Kind() != reflect.Struct {
+		return nil, errortools.ErrorMessage("Models are not (pointers to) structs.")
+	}
+
+	if oldValue.Type() != newValue.Type() {
+		return nil, errortools.ErrorMessagef("Cannot compare %s with %s.", oldValue.Type(), newValue.Type())
+	}
+
+	changes := []FieldChange{}
+
+	for _, field := range structFields(oldValue.Type(), tagName, false, &FlattenOptions{}) {
+		_old := diffValue(fieldByIndex(oldValue, field.index))
+		_new := diffValue(fieldByIndex(newValue, field.index))
+
+		if diffEqual(_old, _new) {
+			continue
+		}
+
+		changes = append(changes, FieldChange{
+			Name:     field.name,
+			Field:    field.fieldName,
+			OldValue: _old,
+			NewValue: _new,
+		})
+	}
+
+	return changes, nil
+}
+
+// diffValue returns the value of v to compare, nil for nil pointers and invalid bigquery.Null values
+func diffValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		// nested in nil pointer
+		return nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v, _ = bigQueryNullValue(v); !v.IsValid() {
+		return nil
+	}
+
+	return v.Interface()
+}
+
+func diffEqual(a interface{}, b interface{}) bool {
+	if ta, ok := a.(time.Time); ok {
+		if tb, ok := b.(time.Time); ok {
+			return ta.Equal(tb)
+		}
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// ApplyPatch writes patch onto model, a pointer to a struct, using SetStructFieldByTagFlattened.
+// Patch is either a []FieldChange, of which the NewValue is set, or a map[string]interface{}
+// keyed by tag name or path, in which nested maps are applied to nested structs.
+// Values are converted to the type of the field as by SetStructFieldByTagOf.
+func ApplyPatch(model interface{}, tagName string, patch interface{}) *errortools.Error {
+	if reflect.TypeOf(model).Kind() != reflect.Ptr || reflect.TypeOf(model).Elem().Kind() != reflect.Struct {
+		return errortools.ErrorMessage("Model is not a pointer to a struct.")
+	}
+
+	fields := cachedStructFields(reflect.TypeOf(model).Elem(), tagName, false, &FlattenOptions{})
+
+	values := make(map[string]interface{})
+
+	switch p := patch.(type) {
+	case []FieldChange:
+		for _, change := range p {
+			values[change.Name] = change.NewValue
+		}
+	case map[string]interface{}:
+		flattenPatch(values, p, "", fields)
+	default:
+		return errortools.ErrorMessagef("Invalid patch type %T.", patch)
+	}
+
+	// sort names for deterministic error reporting
+	names := []string{}
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if _, ok := fields.field(name); !ok {
+			return errortools.ErrorMessagef("Type %s has no field with %s tag '%s'.", reflect.TypeOf(model).Elem(), tagName, name)
+		}
+
+		e := SetStructFieldByTagFlattened(model, tagName, name, values[name], nil, nil)
+		if e != nil {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// flattenPatch adds the values of patch to values, keyed by path
+func flattenPatch(values map[string]interface{}, patch map[string]interface{}, prefix string, fields *structFieldList) {
+	for key, value := range patch {
+		name := prefix + key
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			if _, isField := fields.field(name); !isField {
+				flattenPatch(values, nested, name+defaultFlattenSeparator, fields)
+				continue
+			}
+		}
+
+		values[name] = value
+	}
+}
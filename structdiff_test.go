@@ -0,0 +1,88 @@
+package utilities
+
+import (
+	"cloud.google.com/go/bigquery"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type diffAddress struct {
+	City string `json:"city"`
+}
+
+type diffModel struct {
+	Id       int                `json:"id"`
+	Name     string             `json:"name"`
+	Score    bigquery.NullInt64 `json:"score"`
+	Updated  time.Time          `json:"updated"`
+	Address  *diffAddress       `json:"address"`
+	Untagged string
+}
+
+func TestStructDiff(t *testing.T) {
+	updated := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	oldModel := diffModel{Id: 1, Name: "alice", Updated: updated, Untagged: "a"}
+	newModel := diffModel{Id: 1, Name: "bob", Score: bigquery.NullInt64{Int64: 3, Valid: true}, Updated: updated.In(time.FixedZone("CET", 3600)), Address: &diffAddress{City: "Utrecht"}, Untagged: "b"}
+
+	changes, e := StructDiff(&oldModel, newModel, "json")
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	// equal instants in other zones and untagged fields are not reported
+	expected := []FieldChange{
+		{Name: "name", Field: "Name", OldValue: "alice", NewValue: "bob"},
+		{Name: "score", Field: "Score", OldValue: nil, NewValue: int64(3)},
+		{Name: "address.city", Field: "Address.City", OldValue: nil, NewValue: "Utrecht"},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("got %+v, expected %+v", changes, expected)
+	}
+
+	if _, e = StructDiff(oldModel, diffAddress{}, "json"); e == nil {
+		t.Fatal("compared different types")
+	}
+}
+
+func TestApplyPatchRoundTrip(t *testing.T) {
+	oldModel := diffModel{Id: 1, Name: "alice"}
+	newModel := diffModel{Id: 2, Name: "bob", Score: bigquery.NullInt64{Int64: 3, Valid: true}, Address: &diffAddress{City: "Utrecht"}}
+
+	changes, e := StructDiff(oldModel, newModel, "json")
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+
+	patched := oldModel
+	if e = ApplyPatch(&patched, "json", changes); e != nil {
+		t.Fatal(e.Message())
+	}
+	if !reflect.DeepEqual(patched, newModel) {
+		t.Fatalf("patched %+v, expected %+v", patched, newModel)
+	}
+}
+
+func TestApplyPatchMap(t *testing.T) {
+	model := diffModel{Id: 1}
+
+	patch := map[string]interface{}{
+		"id":      float64(2),
+		"name":    "carol",
+		"address": map[string]interface{}{"city": "Delft"},
+	}
+	if e := ApplyPatch(&model, "json", patch); e != nil {
+		t.Fatal(e.Message())
+	}
+	if model.Id != 2 || model.Name != "carol" || model.Address == nil || model.Address.City != "Delft" {
+		t.Fatalf("got %+v", model)
+	}
+
+	if e := ApplyPatch(&model, "json", map[string]interface{}{"unknown": 1}); e == nil {
+		t.Fatal("applied patch of unknown field")
+	}
+	if e := ApplyPatch(&model, "json", map[string]interface{}{"id": 1.5}); e == nil {
+		t.Fatal("applied lossy conversion")
+	}
+}
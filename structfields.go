@@ -0,0 +1,291 @@
+package utilities
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+const defaultFlattenSeparator string = "."
+
+// FlattenOptions makes the struct helpers walk embedded and nested structs.
+// Fields of embedded structs are promoted, fields of nested structs are named
+// by their path, e.g. `address.city`, joined by Separator (default ".").
+type FlattenOptions struct {
+	Separator    *string
+	embeddedOnly bool
+}
+
+// promoteEmbedded only promotes the fields of embedded structs without name in their tag,
+// as encoding/json does, leaving nested structs a single field
+var promoteEmbedded = &FlattenOptions{embeddedOnly: true}
+
+func (flatten *FlattenOptions) separator() string {
+	if flatten == nil || flatten.Separator == nil {
+		return defaultFlattenSeparator
+	}
+
+	return *flatten.Separator
+}
+
+// flattenOrDefault returns flatten, or the default FlattenOptions if nil
+func flattenOrDefault(flatten *FlattenOptions) *FlattenOptions {
+	if flatten == nil {
+		return &FlattenOptions{}
+	}
+
+	return flatten
+}
+
+// structField describes a (possibly nested) field of a struct type.
+// basicFormat and basicParse are resolved once per type: they are set if values of the field
+// are strings, bools or numbers without registered formatter or parser, so formatting and
+// parsing can skip the registry and type checks of formatValue and parseString.
+type structField struct {
+	index       []int
+	name        string
+	fieldName   string
+	options     tagOptions
+	field       reflect.StructField
+	basicFormat bool
+	basicParse  bool
+}
+
+func newStructField(index []int, name string, fieldName string, options tagOptions, field reflect.StructField) structField {
+	t := field.Type
+
+	return structField{
+		index:       index,
+		name:        name,
+		fieldName:   fieldName,
+		options:     options,
+		field:       field,
+		basicFormat: isBasicKind(t.Kind()) && registry.formatter(t) == nil && !t.Implements(textMarshalerType),
+		basicParse:  isBasicKind(t.Kind()) && registry.parser(t) == nil && !reflect.PointerTo(t).Implements(textUnmarshalerType),
+	}
+}
+
+// formatString formats f, the value of the field, as getStructFieldString does
+func (field *structField) formatString(f reflect.Value, fieldLayouts *FieldLayouts) string {
+	if field.basicFormat {
+		return formatBasic(f, fieldLayouts)
+	}
+
+	return getStructFieldString(f, fieldLayouts)
+}
+
+// convert converts value to the type of the field, as convertValue does
+func (field *structField) convert(value interface{}, fieldLayouts *FieldLayouts) (reflect.Value, error) {
+	if s, ok := value.(string); ok && field.basicParse {
+		return parseBasic(s, field.field.Type, fieldLayouts)
+	}
+
+	return convertValue(value, field.field.Type, fieldLayouts)
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// structFieldList holds the fields of a struct type, indexed by name
+type structFieldList struct {
+	fields []structField
+	byName map[string]int
+}
+
+func (list *structFieldList) field(name string) (structField, bool) {
+	i, ok := list.byName[name]
+	if !ok {
+		return structField{}, false
+	}
+
+	return list.fields[i], true
+}
+
+type structFieldsKey struct {
+	t               reflect.Type
+	tagName         string
+	includeUntagged bool
+	flatten         bool
+	embeddedOnly    bool
+	separator       string
+}
+
+// structFieldsCache caches the structFieldList per structFieldsKey
+var structFieldsCache sync.Map
+
+// clearStructFieldsCache clears the cache, needed when the outcome of isFlattenable
+// or the resolved formatting and parsing of fields changes
+func clearStructFieldsCache() {
+	structFieldsCache.Range(func(key, value interface{}) bool {
+		structFieldsCache.Delete(key)
+		return true
+	})
+}
+
+// structFields returns the fields of struct type t named after tag tagName.
+// Fields without tag are skipped, unless includeUntagged is set in which case they
+// are named after the field. Fields tagged "-" and unexported fields are always skipped.
+// If flatten is not nil, embedded and, unless flatten is promoteEmbedded, nested structs are walked recursively.
+// The returned slice is cached and shared, so must not be modified.
+func structFields(t reflect.Type, tagName string, includeUntagged bool, flatten *FlattenOptions) []structField {
+	return cachedStructFields(t, tagName, includeUntagged, flatten).fields
+}
+
+func cachedStructFields(t reflect.Type, tagName string, includeUntagged bool, flatten *FlattenOptions) *structFieldList {
+	key := structFieldsKey{
+		t:               t,
+		tagName:         tagName,
+		includeUntagged: includeUntagged,
+		flatten:         flatten != nil,
+		embeddedOnly:    flatten != nil && flatten.embeddedOnly,
+		separator:       flatten.separator(),
+	}
+
+	cached, ok := structFieldsCache.Load(key)
+	if ok {
+		return cached.(*structFieldList)
+	}
+
+	list := structFieldList{
+		fields: appendStructFields(nil, t, tagName, includeUntagged, flatten, nil, "", "", map[reflect.Type]bool{}),
+		byName: make(map[string]int),
+	}
+
+	for i := len(list.fields) - 1; i >= 0; i-- {
+		// first field wins in case of duplicate names
+		list.byName[list.fields[i].name] = i
+	}
+
+	cached, _ = structFieldsCache.LoadOrStore(key, &list)
+
+	return cached.(*structFieldList)
+}
+
+func appendStructFields(fields []structField, t reflect.Type, tagName string, includeUntagged bool, flatten *FlattenOptions, index []int, namePrefix string, fieldNamePrefix string, visited map[reflect.Type]bool) []structField {
+	// guard against recursive types
+	if visited[t] {
+		return fields
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag, tagged := field.Tag.Lookup(tagName)
+		if tag == "-" {
+			continue
+		}
+
+		name, options := parseTag(tag)
+
+		_index := append(append([]int{}, index...), i)
+
+		if flatten != nil && isFlattenable(field.Type) {
+			structType := field.Type
+			if structType.Kind() == reflect.Ptr {
+				structType = structType.Elem()
+			}
+
+			if field.Anonymous && name == "" {
+				fields = appendStructFields(fields, structType, tagName, includeUntagged, flatten, _index, namePrefix, fieldNamePrefix, visited)
+				continue
+			}
+
+			if field.IsExported() && (tagged || includeUntagged) && !flatten.embeddedOnly {
+				if name == "" {
+					name = field.Name
+				}
+				fields = appendStructFields(fields, structType, tagName, includeUntagged, flatten, _index, namePrefix+name+flatten.separator(), fieldNamePrefix+field.Name+flatten.separator(), visited)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if !tagged && !includeUntagged {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields = append(fields, newStructField(_index, namePrefix+name, fieldNamePrefix+field.Name, options, field))
+	}
+
+	return fields
+}
+
+// isFlattenable reports whether t is a (pointer to a) struct that is not handled as a single value,
+// such as time.Time, civil and bigquery.Null types or types with a registered formatter
+func isFlattenable(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	switch t {
+	case timeType, civilDateType, civilTimeType, civilDateTimeType:
+		return false
+	}
+
+	if isBigQueryNullType(t) {
+		return false
+	}
+
+	if registry.formatter(t) != nil || registry.parser(t) != nil {
+		return false
+	}
+
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return false
+	}
+
+	return true
+}
+
+// fieldByIndex returns the nested field of v with the given index, or an invalid value
+// if a nil pointer is encountered on the way
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v
+}
+
+// errUnexportedEmbeddedPointer is the error for fields promoted from a nil pointer
+// to an unexported embedded struct, which cannot be allocated
+var errUnexportedEmbeddedPointer = fmt.Errorf("cannot set embedded pointer to unexported struct")
+
+// fieldByIndexAlloc returns the nested field of v with the given index, allocating nil pointers
+// to structs on the way. Like encoding/json, it returns an invalid value if a nil pointer is an
+// unexported embedded field, see errUnexportedEmbeddedPointer.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	return v
+}
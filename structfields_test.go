@@ -0,0 +1,222 @@
+package utilities
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type benchmarkRow struct {
+	Id        int64     `csv:"id" json:"id"`
+	Code      string    `csv:"code" json:"code"`
+	Name      string    `csv:"name" json:"name"`
+	Quantity  int       `csv:"quantity" json:"quantity"`
+	Price     float64   `csv:"price" json:"price"`
+	Active    bool      `csv:"active" json:"active"`
+	Category  string    `csv:"category" json:"category"`
+	Weight    float32   `csv:"weight" json:"weight"`
+	Stock     uint32    `csv:"stock" json:"stock"`
+	UpdatedAt time.Time `csv:"updated_at" json:"updated_at"`
+}
+
+var benchmarkRows = func() []benchmarkRow {
+	rows := make([]benchmarkRow, 100)
+	for i := range rows {
+		rows[i] = benchmarkRow{
+			Id:        int64(i),
+			Code:      "A-001",
+			Name:      "Article",
+			Quantity:  12,
+			Price:     9.95,
+			Active:    true,
+			Category:  "tools",
+			Weight:    1.5,
+			Stock:     250,
+			UpdatedAt: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC),
+		}
+	}
+	return rows
+}()
+
+// benchmarkCache runs f with the struct field cache, then clearing the cache before each call,
+// which walks the struct and parses its tags each time as the helpers did before caching
+func benchmarkCache(b *testing.B, f func(b *testing.B)) {
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			f(b)
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			clearStructFieldsCache()
+			f(b)
+		}
+	})
+}
+
+func BenchmarkSetStructFieldByTag(b *testing.B) {
+	var row benchmarkRow
+
+	benchmarkCache(b, func(b *testing.B) {
+		if e := SetStructFieldByTag(&row, "json", "stock", "250"); e != nil {
+			b.Fatal(e.Message())
+		}
+	})
+}
+
+func BenchmarkGetTaggedTagNames(b *testing.B) {
+	benchmarkCache(b, func(b *testing.B) {
+		if GetTaggedTagNames("csv", benchmarkRow{}) == "" {
+			b.Fatal("no tag names")
+		}
+	})
+}
+
+func BenchmarkStructToStringArray(b *testing.B) {
+	benchmarkCache(b, func(b *testing.B) {
+		if _, e := StructToStringArray(&benchmarkRows, true); e != nil {
+			b.Fatal(e.Message())
+		}
+	})
+}
+
+func BenchmarkStructToUrl(b *testing.B) {
+	tag := "json"
+
+	benchmarkCache(b, func(b *testing.B) {
+		if _, e := StructToUrl(&benchmarkRows[0], &tag); e != nil {
+			b.Fatal(e.Message())
+		}
+	})
+}
+
+type flattenAddress struct {
+	Street string `csv:"street" json:"street"`
+	City   string `csv:"city" json:"city"`
+}
+
+type flattenBase struct {
+	Id int `csv:"id" json:"id"`
+}
+
+type flattenInner struct {
+	Country string `csv:"country" json:"country"`
+}
+
+type flattenRow struct {
+	flattenBase
+	*flattenInner
+	Name    string          `csv:"name" json:"name"`
+	Address *flattenAddress `csv:"address" json:"address"`
+}
+
+func TestFlattenNames(t *testing.T) {
+	names := GetTaggedTagNamesFlattened("csv", flattenRow{}, nil)
+	if names != "id,country,name,address.street,address.city" {
+		t.Fatalf("got names '%s'", names)
+	}
+
+	separator := "_"
+	names = GetTaggedFieldNamesFlattened("csv", flattenRow{}, &FlattenOptions{Separator: &separator})
+	if names != "Id,Country,Name,Address_Street,Address_City" {
+		t.Fatalf("got field names '%s'", names)
+	}
+
+	// without flattening nested structs are a single field and embedded structs are skipped
+	if names := GetTaggedTagNames("csv", flattenRow{}); names != "name,address" {
+		t.Fatalf("got names '%s'", names)
+	}
+}
+
+func TestFlattenSetStructFieldByTag(t *testing.T) {
+	var row flattenRow
+
+	if e := SetStructFieldByTagFlattened(&row, "csv", "address.city", "Utrecht", nil, nil); e != nil {
+		t.Fatal(e.Message())
+	}
+	if e := SetStructFieldByTagFlattened(&row, "csv", "id", "3", nil, nil); e != nil {
+		t.Fatal(e.Message())
+	}
+	if row.Address == nil || row.Address.City != "Utrecht" || row.Id != 3 {
+		t.Fatalf("got %+v", row)
+	}
+
+	// a nil pointer to an unexported embedded struct cannot be allocated
+	if e := SetStructFieldByTagFlattened(&row, "csv", "country", "NL", nil, nil); e == nil {
+		t.Fatal("set field of nil unexported embedded pointer")
+	}
+
+	row.flattenInner = &flattenInner{}
+	if e := SetStructFieldByTagFlattened(&row, "csv", "country", "NL", nil, nil); e != nil {
+		t.Fatal(e.Message())
+	}
+	if row.Country != "NL" {
+		t.Fatalf("got %+v", row.flattenInner)
+	}
+}
+
+func TestFlattenCsvRoundTrip(t *testing.T) {
+	options := &CsvOptions{Flatten: &FlattenOptions{}}
+
+	rows := []flattenRow{
+		{flattenBase: flattenBase{Id: 1}, Name: "alice", Address: &flattenAddress{Street: "Main", City: "Utrecht"}},
+		{flattenBase: flattenBase{Id: 2}, Name: "bob"},
+	}
+
+	data := encodeCsv(t, rows, options)
+	expected := "id,country,name,address.street,address.city\n1,,alice,Main,Utrecht\n2,,bob,,\n"
+	if data != expected {
+		t.Fatalf("encoded\n%s\nexpected\n%s", data, expected)
+	}
+
+	decoded := decodeCsvRows[flattenRow](t, data, options)
+	if len(decoded) != 2 || decoded[0].Address == nil || *decoded[0].Address != *rows[0].Address || decoded[0].Id != 1 {
+		t.Fatalf("decoded %+v", decoded)
+	}
+	// empty values leave nil pointers to nested structs untouched
+	if decoded[1].Address != nil || decoded[1].Name != "bob" {
+		t.Fatalf("decoded %+v", decoded[1])
+	}
+}
+
+func TestFlattenUnexportedEmbeddedPointer(t *testing.T) {
+	options := &CsvOptions{Flatten: &FlattenOptions{}}
+
+	records := [][]string{{"id", "country", "name"}, {"1", "NL", "alice"}}
+
+	var rows []flattenRow
+	fieldErrors, e := StringArrayToStructWithOptions(&records, &rows, options)
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	if len(fieldErrors) != 1 || fieldErrors[0].Column != "country" || fieldErrors[0].Err != errUnexportedEmbeddedPointer {
+		t.Fatalf("got field errors %v", fieldErrors)
+	}
+	if len(rows) != 1 || rows[0].Id != 1 || rows[0].Name != "alice" || rows[0].flattenInner != nil {
+		t.Fatalf("got %+v", rows)
+	}
+
+	decoder := NewCsvDecoder(bytes.NewBufferString("id,country\n1,NL\n"), options)
+	for decoder.Next() {
+		var row flattenRow
+		if e := decoder.Decode(&row); e != nil {
+			t.Fatal(e.Message())
+		}
+	}
+	if len(decoder.FieldErrors()) != 1 {
+		t.Fatalf("got field errors %v", decoder.FieldErrors())
+	}
+
+	tag := "csv"
+	var row flattenRow
+	fieldErrors, e = UrlToStruct("id=1&country=NL", &row, &UrlOptions{Tag: &tag, Flatten: &FlattenOptions{}})
+	if e != nil {
+		t.Fatal(e.Message())
+	}
+	if len(fieldErrors) != 1 || row.Id != 1 {
+		t.Fatalf("got %+v, field errors %v", row, fieldErrors)
+	}
+}
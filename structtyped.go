@@ -0,0 +1,159 @@
+package utilities
+
+import (
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// typedStruct holds the validated struct type used by the generic struct helpers
+type typedStruct struct {
+	structType reflect.Type
+	fields     map[string][]int // field index by lower case field name
+}
+
+// typedStructs caches the typedStruct per struct type
+var typedStructs sync.Map
+
+// typedStructOf validates T on first use, returning an error if it is not a struct type
+func typedStructOf[T any]() (*typedStruct, *errortools.Error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+
+	cached, ok := typedStructs.Load(t)
+	if ok {
+		return cached.(*typedStruct), nil
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil, errortools.ErrorMessagef("Type %s is not a struct.", t)
+	}
+
+	_typedStruct := typedStruct{
+		structType: t,
+		fields:     make(map[string][]int),
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.ToLower(field.Name)
+		if _, ok := _typedStruct.fields[name]; ok {
+			return nil, errortools.ErrorMessagef("Type %s has multiple fields named '%s'.", t, field.Name)
+		}
+		_typedStruct.fields[name] = field.Index
+	}
+
+	cached, _ = typedStructs.LoadOrStore(t, &_typedStruct)
+
+	return cached.(*typedStruct), nil
+}
+
+func (s *typedStruct) field(v reflect.Value, fieldName string) (reflect.Value, *errortools.Error) {
+	index, ok := s.fields[strings.ToLower(fieldName)]
+	if !ok {
+		return reflect.Value{}, errortools.ErrorMessagef("Type %s has no field '%s'.", s.structType, fieldName)
+	}
+
+	return v.FieldByIndex(index), nil
+}
+
+// HasStructFieldOf reports whether struct type T has an exported field named fieldName (case insensitive)
+func HasStructFieldOf[T any](fieldName string) (bool, *errortools.Error) {
+	s, e := typedStructOf[T]()
+	if e != nil {
+		return false, e
+	}
+
+	_, ok := s.fields[strings.ToLower(fieldName)]
+
+	return ok, nil
+}
+
+// SetStructFieldOf sets field fieldName (case insensitive) of model to value, converting value
+// to the type of the field if needed, see SetStructFieldByTagOf
+func SetStructFieldOf[T any](model *T, fieldName string, value interface{}) *errortools.Error {
+	if model == nil {
+		return errortools.ErrorMessage("Model is nil.")
+	}
+
+	s, e := typedStructOf[T]()
+	if e != nil {
+		return e
+	}
+
+	f, e := s.field(reflect.ValueOf(model).Elem(), fieldName)
+	if e != nil {
+		return e
+	}
+
+	v, err := convertValue(value, f.Type(), nil)
+	if err != nil {
+		return errortools.ErrorMessagef("Field '%s': %s", fieldName, err.Error())
+	}
+	f.Set(v)
+
+	return nil
+}
+
+// SetStructFieldByTagOf sets the field of model having tag to value. Values are converted
+// to the type of the field if this is lossless: strings are parsed using fieldLayouts,
+// numbers converted as long as they fit, e.g. float64 3 to int but not 3.5.
+// An error is returned if no field has tag or value cannot be converted.
+func SetStructFieldByTagOf[T any](model *T, tagName string, tag string, value interface{}, fieldLayouts *FieldLayouts) *errortools.Error {
+	if model == nil {
+		return errortools.ErrorMessage("Model is nil.")
+	}
+
+	s, e := typedStructOf[T]()
+	if e != nil {
+		return e
+	}
+
+	field, ok := cachedStructFields(s.structType, tagName, false, nil).field(tag)
+	if !ok {
+		return errortools.ErrorMessagef("Type %s has no field with %s tag '%s'.", s.structType, tagName, tag)
+	}
+
+	f := reflect.ValueOf(model).Elem().FieldByIndex(field.index)
+
+	v, err := convertValue(value, f.Type(), fieldLayouts)
+	if err != nil {
+		return errortools.ErrorMessagef("Field '%s': %s", tag, err.Error())
+	}
+	f.Set(v)
+
+	return nil
+}
+
+// GetStructFieldStringOf returns the value of field fieldName (case insensitive) of model formatted
+// as string, returning "" for zero values like GetStructFieldStringByFieldName
+func GetStructFieldStringOf[T any](model *T, fieldName string, fieldLayouts *FieldLayouts) (string, *errortools.Error) {
+	if model == nil {
+		return "", errortools.ErrorMessage("Model is nil.")
+	}
+
+	s, e := typedStructOf[T]()
+	if e != nil {
+		return "", e
+	}
+
+	f, e := s.field(reflect.ValueOf(model).Elem(), fieldName)
+	if e != nil {
+		return "", e
+	}
+
+	if f.IsZero() {
+		return "", nil
+	}
+
+	value, err := formatValue(f, fieldLayouts)
+	if err != nil {
+		return "", errortools.ErrorMessagef("Field '%s': %s", fieldName, err.Error())
+	}
+
+	return value, nil
+}
@@ -0,0 +1,79 @@
+package utilities
+
+import (
+	"testing"
+)
+
+type typedModel struct {
+	Id     int     `json:"id"`
+	Amount float64 `json:"amount"`
+	Name   *string `json:"name"`
+	hidden string
+}
+
+func TestSetStructFieldOf(t *testing.T) {
+	var model typedModel
+
+	if e := SetStructFieldOf(&model, "id", float64(3)); e != nil {
+		t.Fatal(e.Message())
+	}
+	if e := SetStructFieldOf(&model, "NAME", "alice"); e != nil {
+		t.Fatal(e.Message())
+	}
+	if model.Id != 3 || model.Name == nil || *model.Name != "alice" {
+		t.Fatalf("got %+v", model)
+	}
+
+	// conversions losing information fail
+	if e := SetStructFieldOf(&model, "id", 3.5); e == nil {
+		t.Fatal("set int field to 3.5")
+	}
+	if e := SetStructFieldOf(&model, "unknown", 1); e == nil {
+		t.Fatal("set unknown field")
+	}
+	if e := SetStructFieldOf(&model, "hidden", "x"); e == nil {
+		t.Fatal("set unexported field")
+	}
+	if e := SetStructFieldOf[typedModel](nil, "id", 1); e == nil {
+		t.Fatal("set field of nil model")
+	}
+}
+
+func TestSetStructFieldByTagOf(t *testing.T) {
+	var model typedModel
+
+	decimalSeparator := ","
+	if e := SetStructFieldByTagOf(&model, "json", "amount", "1,5", &FieldLayouts{DecimalSeparator: &decimalSeparator}); e != nil {
+		t.Fatal(e.Message())
+	}
+	if model.Amount != 1.5 {
+		t.Fatalf("got %+v", model)
+	}
+
+	if e := SetStructFieldByTagOf(&model, "json", "unknown", "1", nil); e == nil {
+		t.Fatal("set field of unknown tag")
+	}
+}
+
+func TestHasStructFieldOf(t *testing.T) {
+	if ok, e := HasStructFieldOf[typedModel]("amount"); e != nil || !ok {
+		t.Fatalf("got %v, %v", ok, e)
+	}
+	if ok, e := HasStructFieldOf[typedModel]("hidden"); e != nil || ok {
+		t.Fatalf("got %v, %v for unexported field", ok, e)
+	}
+	if _, e := HasStructFieldOf[int]("x"); e == nil {
+		t.Fatal("accepted non struct type")
+	}
+}
+
+func TestGetStructFieldStringOf(t *testing.T) {
+	model := typedModel{Id: 12}
+
+	if s, e := GetStructFieldStringOf(&model, "id", nil); e != nil || s != "12" {
+		t.Fatalf("got '%s', %v", s, e)
+	}
+	if s, e := GetStructFieldStringOf(&model, "amount", nil); e != nil || s != "" {
+		t.Fatalf("got '%s', %v for zero value", s, e)
+	}
+}
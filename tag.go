@@ -0,0 +1,46 @@
+package utilities
+
+import (
+	"strings"
+)
+
+// tagOptions is the string following a comma in a struct field's tag, or the empty string
+type tagOptions string
+
+// parseTag splits a struct field's tag into its name and comma-separated options
+func parseTag(tag string) (string, tagOptions) {
+	name, options, _ := strings.Cut(tag, ",")
+	return name, tagOptions(options)
+}
+
+// Contains reports whether a comma-separated list of options contains a particular option
+func (options tagOptions) Contains(option string) bool {
+	if len(options) == 0 {
+		return false
+	}
+
+	s := string(options)
+	for s != "" {
+		var name string
+		name, s, _ = strings.Cut(s, ",")
+		if name == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Value returns the value of an option of the form option=value
+func (options tagOptions) Value(option string) (string, bool) {
+	s := string(options)
+	for s != "" {
+		var name string
+		name, s, _ = strings.Cut(s, ",")
+		if value, ok := strings.CutPrefix(name, option+"="); ok {
+			return value, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,303 @@
+package utilities
+
+import (
+	"cloud.google.com/go/civil"
+	"fmt"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const validateTag string = "validate"
+
+// validateRegexps caches the compiled patterns of regex rules
+var validateRegexps sync.Map
+
+// ValidateStruct validates model, a (pointer to a) struct, against the rules in the `validate` tag
+// of its fields, nested structs included, e.g. `validate:"required,min=1,max=10"`. Supported rules:
+//
+//	required        value is not zero, pointers and bigquery.Null* values are not nil or invalid
+//	min=n, max=n    numbers: value, strings (in runes), slices and maps: length,
+//	                time.Time and civil.Date: date, either a date (2006-01-02) or "now"
+//	len=n           length of strings (in runes), slices and maps
+//	oneof=a b c     value formatted as string is one of the space separated values
+//	email           value is an email address
+//	url             value is an absolute url
+//	regex=pattern   value formatted as string matches pattern, must be the last rule
+//
+// Rules are not applied to nil pointers and invalid bigquery.Null values, except required.
+// Zero values are checked by min, max and len, but not by the other rules, so e.g. an empty
+// string passes email. The tag of a nested struct is applied to the field holding it before its
+// own fields are validated, which are skipped if it is a nil pointer.
+// An error is returned for each field violating one of its rules, nil if model is valid.
+func ValidateStruct(model interface{}) []*errortools.Error {
+	v := reflect.ValueOf(model)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return []*errortools.Error{errortools.ErrorMessage("The interface is not a (pointer to a) struct.")}
+	}
+
+	return validateStruct(v, "", map[reflect.Type]bool{})
+}
+
+// validateStruct validates the fields of struct v, prefix being the path of field names leading to v
+func validateStruct(v reflect.Value, prefix string, visited map[reflect.Type]bool) []*errortools.Error {
+	// guard against recursive types
+	if visited[v.Type()] {
+		return nil
+	}
+	visited[v.Type()] = true
+	defer delete(visited, v.Type())
+
+	var errors []*errortools.Error
+
+	for _, field := range structFields(v.Type(), "", true, nil) {
+		f := v.FieldByIndex(field.index)
+		fieldName := prefix + field.fieldName
+
+		tag := field.field.Tag.Get(validateTag)
+		if tag != "" {
+			err := validateField(f, tag)
+			if err != nil {
+				errors = append(errors, errortools.ErrorMessagef("Field '%s': %s", fieldName, err.Error()))
+			}
+		}
+
+		if !isFlattenable(f.Type()) {
+			continue
+		}
+
+		if f.Kind() == reflect.Ptr {
+			if f.IsNil() {
+				continue
+			}
+			f = f.Elem()
+		}
+
+		// fields of embedded structs are promoted
+		nestedPrefix := fieldName + defaultFlattenSeparator
+		if field.field.Anonymous {
+			nestedPrefix = prefix
+		}
+
+		errors = append(errors, validateStruct(f, nestedPrefix, visited)...)
+	}
+
+	return errors
+}
+
+// validateField returns an error for the first rule in tag that v violates
+func validateField(v reflect.Value, tag string) error {
+	// pointers and bigquery.Null* values are only required not to be nil or invalid
+	nullable := v.Kind() == reflect.Ptr || isBigQueryNullType(v.Type())
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v = reflect.Value{}
+		} else {
+			v = v.Elem()
+		}
+	}
+
+	if v.IsValid() {
+		v, _ = bigQueryNullValue(v)
+	}
+
+	absent := !v.IsValid()
+	empty := absent || v.IsZero()
+
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "regex=") {
+			// the pattern may contain commas
+			rule, tag = tag, ""
+		} else {
+			rule, tag, _ = strings.Cut(tag, ",")
+		}
+
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		if name == "required" {
+			if absent || (empty && !nullable) {
+				return fmt.Errorf("value is required")
+			}
+			continue
+		}
+
+		if absent || (empty && name != "min" && name != "max" && name != "len") {
+			continue
+		}
+
+		err := validateRule(v, name, param)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateRule(v reflect.Value, name string, param string) error {
+	switch name {
+	case "min", "max":
+		return validateRange(v, name, param)
+	case "len":
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return fmt.Errorf("invalid len '%s'", param)
+		}
+		length, ok := validateLength(v)
+		if !ok {
+			return fmt.Errorf("len is not supported for type %s", v.Type())
+		}
+		if length != n {
+			return fmt.Errorf("length %v is not %v", length, n)
+		}
+	case "oneof":
+		value, err := formatValue(v, nil)
+		if err != nil {
+			return err
+		}
+		for _, option := range strings.Fields(param) {
+			if value == option {
+				return nil
+			}
+		}
+		return fmt.Errorf("value '%s' is not one of '%s'", value, param)
+	case "email":
+		if v.Kind() != reflect.String || !emailRegexp.MatchString(v.String()) {
+			return fmt.Errorf("value '%v' is not a valid email address", v.Interface())
+		}
+	case "url":
+		if v.Kind() != reflect.String {
+			return fmt.Errorf("url is not supported for type %s", v.Type())
+		}
+		u, err := url.ParseRequestURI(v.String())
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("value '%s' is not a valid url", v.String())
+		}
+	case "regex":
+		re, err := validateRegexp(param)
+		if err != nil {
+			return err
+		}
+		value, err := formatValue(v, nil)
+		if err != nil {
+			return err
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("value '%s' does not match '%s'", value, param)
+		}
+	default:
+		return fmt.Errorf("unknown validation rule '%s'", name)
+	}
+
+	return nil
+}
+
+func validateRange(v reflect.Value, name string, param string) error {
+	var compare int
+
+	switch v.Type() {
+	case timeType, civilDateType:
+		bound, err := validateDate(param)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s'", name, param)
+		}
+
+		var value time.Time
+		if v.Type() == timeType {
+			value = v.Interface().(time.Time)
+		} else {
+			value = v.Interface().(civil.Date).In(time.UTC)
+		}
+
+		compare = value.Compare(bound)
+	default:
+		bound, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s '%s'", name, param)
+		}
+
+		var value float64
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			value = float64(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			value = float64(v.Uint())
+		case reflect.Float32, reflect.Float64:
+			value = v.Float()
+		default:
+			length, ok := validateLength(v)
+			if !ok {
+				return fmt.Errorf("%s is not supported for type %s", name, v.Type())
+			}
+			value = float64(length)
+		}
+
+		switch {
+		case value < bound:
+			compare = -1
+		case value > bound:
+			compare = 1
+		}
+	}
+
+	if (name == "min" && compare < 0) || (name == "max" && compare > 0) {
+		value, _ := formatValue(v, nil)
+		if compare < 0 {
+			return fmt.Errorf("value '%s' is less than %s", value, param)
+		}
+		return fmt.Errorf("value '%s' is greater than %s", value, param)
+	}
+
+	return nil
+}
+
+func validateLength(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), true
+	}
+
+	return 0, false
+}
+
+func validateDate(param string) (time.Time, error) {
+	if param == "now" {
+		return time.Now(), nil
+	}
+
+	date, err := civil.ParseDate(param)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return date.In(time.UTC), nil
+}
+
+func validateRegexp(pattern string) (*regexp.Regexp, error) {
+	cached, ok := validateRegexps.Load(pattern)
+	if ok {
+		return cached.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex '%s': %s", pattern, err.Error())
+	}
+	validateRegexps.Store(pattern, re)
+
+	return re, nil
+}
@@ -0,0 +1,125 @@
+package utilities
+
+import (
+	"cloud.google.com/go/bigquery"
+	errortools "github.com/leapforce-libraries/go_errortools"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type validateAddress struct {
+	City string `validate:"required"`
+}
+
+type ValidateBase struct {
+	Code string `validate:"len=3"`
+}
+
+type validateModel struct {
+	ValidateBase
+	Name     string             `validate:"required,max=5"`
+	Age      int                `validate:"min=18"`
+	Email    string             `validate:"email"`
+	Site     string             `validate:"url"`
+	Kind     string             `validate:"oneof=a b"`
+	Ref      string             `validate:"regex=^[A-Z]{2},[0-9]+$"`
+	Tags     []string           `validate:"max=2"`
+	Born     time.Time          `validate:"max=now"`
+	Score    bigquery.NullInt64 `validate:"required,min=1"`
+	Nickname *string            `validate:"min=2"`
+	Address  *validateAddress   `validate:"required"`
+}
+
+func validModel() validateModel {
+	nickname := "al"
+
+	return validateModel{
+		ValidateBase: ValidateBase{Code: "abc"},
+		Name:         "alice",
+		Age:          18,
+		Email:        "alice@example.com",
+		Site:         "https://example.com/a",
+		Kind:         "b",
+		Ref:          "AB,12",
+		Tags:         []string{"a"},
+		Born:         time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC),
+		Score:        bigquery.NullInt64{Int64: 1, Valid: true},
+		Nickname:     &nickname,
+		Address:      &validateAddress{City: "Utrecht"},
+	}
+}
+
+func validateMessages(errors []*errortools.Error) []string {
+	messages := []string{}
+	for _, e := range errors {
+		messages = append(messages, e.Message())
+	}
+
+	return messages
+}
+
+func TestValidateStruct(t *testing.T) {
+	model := validModel()
+	if errors := ValidateStruct(&model); len(errors) > 0 {
+		t.Fatalf("valid model: %v", validateMessages(errors))
+	}
+}
+
+func TestValidateStructRules(t *testing.T) {
+	for field, invalidate := range map[string]func(model *validateModel){
+		"Code":     func(model *validateModel) { model.Code = "ab" },
+		"Name":     func(model *validateModel) { model.Name = "alice!" },
+		"Age":      func(model *validateModel) { model.Age = 17 },
+		"Email":    func(model *validateModel) { model.Email = "alice" },
+		"Site":     func(model *validateModel) { model.Site = "/a" },
+		"Kind":     func(model *validateModel) { model.Kind = "c" },
+		"Ref":      func(model *validateModel) { model.Ref = "A,12" },
+		"Tags":     func(model *validateModel) { model.Tags = []string{"a", "b", "c"} },
+		"Born":     func(model *validateModel) { model.Born = time.Now().Add(time.Hour) },
+		"Score":    func(model *validateModel) { model.Score = bigquery.NullInt64{} },
+		"Nickname": func(model *validateModel) { s := "a"; model.Nickname = &s },
+		"Address":  func(model *validateModel) { model.Address = nil },
+	} {
+		model := validModel()
+		invalidate(&model)
+
+		errors := ValidateStruct(model)
+		if len(errors) != 1 {
+			t.Fatalf("%s: got %v", field, validateMessages(errors))
+		}
+	}
+}
+
+func TestValidateStructZeroValues(t *testing.T) {
+	model := validModel()
+	model.Age = 0
+	model.Code = ""
+	model.Email = ""
+	model.Score = bigquery.NullInt64{Int64: 0, Valid: true}
+	model.Nickname = nil
+
+	// min, max and len apply to zero values, other rules and nil pointers are skipped
+	expected := []string{"Field 'Code': length 0 is not 3", "Field 'Age': value '0' is less than 18", "Field 'Score': value '0' is less than 1"}
+
+	messages := validateMessages(ValidateStruct(&model))
+	if !reflect.DeepEqual(messages, expected) {
+		t.Fatalf("got %v, expected %v", messages, expected)
+	}
+}
+
+func TestValidateStructNested(t *testing.T) {
+	model := validModel()
+	model.Address = &validateAddress{}
+
+	messages := validateMessages(ValidateStruct(&model))
+	if !reflect.DeepEqual(messages, []string{"Field 'Address.City': value is required"}) {
+		t.Fatalf("got %v", messages)
+	}
+}
+
+func TestValidateStructNotStruct(t *testing.T) {
+	if errors := ValidateStruct("x"); len(errors) != 1 {
+		t.Fatalf("got %v", validateMessages(errors))
+	}
+}
@@ -0,0 +1,105 @@
+package utilities
+
+import (
+	"reflect"
+	"sync"
+)
+
+// ValueFormatter formats a value as string
+type ValueFormatter func(value interface{}, fieldLayouts *FieldLayouts) (string, error)
+
+// ValueParser parses a string into a value of type t, t being the type it is registered for
+// or, when registered for an interface type, the type implementing it
+type ValueParser func(value string, t reflect.Type, fieldLayouts *FieldLayouts) (interface{}, error)
+
+type valueRegistry struct {
+	mutex      sync.RWMutex
+	formatters map[reflect.Type]ValueFormatter
+	parsers    map[reflect.Type]ValueParser
+	interfaces []reflect.Type
+}
+
+var registry = valueRegistry{
+	formatters: make(map[reflect.Type]ValueFormatter),
+	parsers:    make(map[reflect.Type]ValueParser),
+}
+
+// RegisterValueFormatter registers the formatter to use for values of type t.
+// If t is an interface type, such as fmt.Stringer or encoding.TextMarshaler, the formatter
+// is used for all types implementing it, unless a formatter is registered for the type itself.
+func RegisterValueFormatter(t reflect.Type, formatter ValueFormatter) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.formatters[t] = formatter
+	registry.registerInterface(t)
+
+	// registered types are no longer flattened nor formatted and parsed as basic values
+	clearStructFieldsCache()
+}
+
+// RegisterValueParser registers the parser to use for values of type t, see RegisterValueFormatter.
+// The parser must return a value assignable to t.
+func RegisterValueParser(t reflect.Type, parser ValueParser) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.parsers[t] = parser
+	registry.registerInterface(t)
+
+	// registered types are no longer flattened nor formatted and parsed as basic values
+	clearStructFieldsCache()
+}
+
+func (registry *valueRegistry) registerInterface(t reflect.Type) {
+	if t.Kind() != reflect.Interface {
+		return
+	}
+
+	for _, i := range registry.interfaces {
+		if i == t {
+			return
+		}
+	}
+
+	registry.interfaces = append(registry.interfaces, t)
+}
+
+func (registry *valueRegistry) formatter(t reflect.Type) ValueFormatter {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	formatter, ok := registry.formatters[t]
+	if ok {
+		return formatter
+	}
+
+	for _, i := range registry.interfaces {
+		formatter, ok := registry.formatters[i]
+		if ok && t.Implements(i) {
+			return formatter
+		}
+	}
+
+	return nil
+}
+
+func (registry *valueRegistry) parser(t reflect.Type) ValueParser {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+
+	parser, ok := registry.parsers[t]
+	if ok {
+		return parser
+	}
+
+	// parsing into an interface requires a pointer receiver, e.g. encoding.TextUnmarshaler
+	for _, i := range registry.interfaces {
+		parser, ok := registry.parsers[i]
+		if ok && (t.Implements(i) || reflect.PointerTo(t).Implements(i)) {
+			return parser
+		}
+	}
+
+	return nil
+}
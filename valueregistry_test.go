@@ -0,0 +1,91 @@
+package utilities
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// registryCode is only used by these tests, the registry being global
+type registryCode string
+
+type registryAmount struct {
+	Cents int64
+}
+
+type registryRow struct {
+	Code   registryCode   `csv:"code"`
+	Amount registryAmount `csv:"amount"`
+}
+
+func init() {
+	RegisterValueFormatter(reflect.TypeOf(registryCode("")), func(value interface{}, fieldLayouts *FieldLayouts) (string, error) {
+		return "#" + strings.ToUpper(string(value.(registryCode))), nil
+	})
+	RegisterValueParser(reflect.TypeOf(registryCode("")), func(value string, t reflect.Type, fieldLayouts *FieldLayouts) (interface{}, error) {
+		return registryCode(strings.ToLower(strings.TrimPrefix(value, "#"))), nil
+	})
+
+	RegisterValueFormatter(reflect.TypeOf(registryAmount{}), func(value interface{}, fieldLayouts *FieldLayouts) (string, error) {
+		cents := value.(registryAmount).Cents
+		return fmt.Sprintf("%d.%02d", cents/100, cents%100), nil
+	})
+	RegisterValueParser(reflect.TypeOf(registryAmount{}), func(value string, t reflect.Type, fieldLayouts *FieldLayouts) (interface{}, error) {
+		var euros, cents int64
+		if _, err := fmt.Sscanf(value, "%d.%02d", &euros, &cents); err != nil {
+			return nil, err
+		}
+		return registryAmount{Cents: euros*100 + cents}, nil
+	})
+}
+
+func TestValueRegistry(t *testing.T) {
+	row := registryRow{Code: "abc", Amount: registryAmount{Cents: 1234}}
+
+	if s := GetStructFieldStringByFieldName(&row, "Code"); s != "#ABC" {
+		t.Fatalf("formatted '%s'", s)
+	}
+	// registered struct types are formatted as a single value, not flattened
+	if s := GetStructFieldStringByFieldName(&row, "Amount"); s != "12.34" {
+		t.Fatalf("formatted '%s'", s)
+	}
+
+	var parsed registryRow
+	if e := SetStructFieldByTag(&parsed, "csv", "code", "#XYZ"); e != nil {
+		t.Fatal(e.Message())
+	}
+	if e := SetStructFieldByTag(&parsed, "csv", "amount", "5.06"); e != nil {
+		t.Fatal(e.Message())
+	}
+	if parsed != (registryRow{Code: "xyz", Amount: registryAmount{Cents: 506}}) {
+		t.Fatalf("parsed %+v", parsed)
+	}
+}
+
+func TestValueRegistryCsvRoundTrip(t *testing.T) {
+	rows := []registryRow{{Code: "abc", Amount: registryAmount{Cents: 1234}}}
+	options := &CsvOptions{Flatten: &FlattenOptions{}}
+
+	data := encodeCsv(t, rows, options)
+	if data != "code,amount\n#ABC,12.34\n" {
+		t.Fatalf("encoded '%s'", data)
+	}
+
+	decoded := decodeCsvRows[registryRow](t, data, options)
+	if !reflect.DeepEqual(decoded, rows) {
+		t.Fatalf("decoded %+v, expected %+v", decoded, rows)
+	}
+}
+
+func TestValueParserInvalidType(t *testing.T) {
+	type invalidParsed int
+
+	RegisterValueParser(reflect.TypeOf(invalidParsed(0)), func(value string, t reflect.Type, fieldLayouts *FieldLayouts) (interface{}, error) {
+		return value, nil
+	})
+
+	if _, err := parseString("1", reflect.TypeOf(invalidParsed(0)), nil); err == nil {
+		t.Fatal("parser returning other type accepted")
+	}
+}